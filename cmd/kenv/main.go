@@ -0,0 +1,196 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/thisendout/kenv"
+)
+
+var (
+	varsFiles            FlagSlice
+	secretFiles          FlagSlice
+	configMapFiles       FlagSlice
+	podPaths             FlagSlice
+	targetContainerLists string
+	containers           FlagSlice
+	selectNames          FlagSlice
+	selectKinds          FlagSlice
+	selectLabels         string
+	envFrom              bool
+	envFromPrefix        string
+	configMapMount       string
+	secretMount          string
+	mountItems           FlagSlice
+	mountMode            string
+	hashSuffix           bool
+	addConfigHash        bool
+	strict               bool
+	secretTypeName       string
+	sealedSecretsCert    string
+	sealingScope         string
+	keySeparator         string
+	upperKeys            bool
+	jsonLists            bool
+	interpolate          bool
+	interpolateEnv       bool
+	ageKeyFile           string
+	execTimeout          time.Duration
+	logLevel             string
+	name                 string
+	namespace            string
+	convertKeys          bool
+	toYAML               bool
+	flagSet              *flag.FlagSet
+)
+
+func init() {
+	// workaround to avoid inheriting vendor flags
+	flagSet = flag.NewFlagSet("kenv", flag.ExitOnError)
+	flagSet.StringVar(&name, "name", "", "Name to give the ConfigMap and Secret resources")
+	flagSet.StringVar(&namespace, "namespace", "default", "Namespace to create the ConfigMap in")
+	flagSet.BoolVar(&convertKeys, "convert-keys", false, "Convert ConfigMap keys to support k8s version < 1.4")
+	flagSet.BoolVar(&envFrom, "env-from", false, "Inject ConfigMaps and Secrets as a single envFrom reference instead of one env entry per key")
+	flagSet.StringVar(&envFromPrefix, "env-from-prefix", "", "Prefix to prepend to each key injected with -env-from")
+	flagSet.StringVar(&configMapMount, "configmap-mount", "", "Mount the ConfigMap as files at this path instead of injecting environment variables")
+	flagSet.StringVar(&secretMount, "secret-mount", "", "Mount the Secret as files at this path instead of injecting environment variables")
+	flagSet.Var(&mountItems, "mount-item", "Only mount a key, at a path relative to the mount, as key=path (repeatable)")
+	flagSet.StringVar(&mountMode, "mount-mode", "", "Octal default file mode of mounted keys, e.g. 0440")
+	flagSet.StringVar(&secretTypeName, "secret-type", "", "Type of the Secret: opaque, tls, docker-registry, basic-auth or ssh-auth")
+	flagSet.StringVar(&sealedSecretsCert, "sealed-secrets-cert", "", "Output a SealedSecret encrypted with this sealed-secrets certificate instead of a Secret")
+	flagSet.StringVar(&sealingScope, "sealing-scope", "strict", "Scope of the SealedSecret: strict, namespace-wide or cluster-wide")
+	flagSet.BoolVar(&hashSuffix, "hash-suffix", false, "Append a hash of the data to the ConfigMap and Secret names so changes trigger a rollout")
+	flagSet.BoolVar(&addConfigHash, "config-hash", false, "Annotate pod templates with a hash of all injected variables so changes trigger a rollout")
+	flagSet.BoolVar(&strict, "strict", false, "Fail on var file lines that would otherwise be skipped")
+	flagSet.StringVar(&keySeparator, "key-separator", kenv.DefaultKeySeparator, "Separator joining the keys of nested YAML and JSON vars")
+	flagSet.BoolVar(&upperKeys, "upper-keys", false, "Upper case the keys of YAML and JSON vars")
+	flagSet.BoolVar(&jsonLists, "json-lists", false, "Inject YAML and JSON lists as a single JSON encoded var")
+	flagSet.BoolVar(&interpolate, "interpolate", false, "Expand ${KEY} references between var values")
+	flagSet.BoolVar(&interpolateEnv, "interpolate-env", false, "Like -interpolate, resolving references missing from the var files from the environment")
+	flagSet.StringVar(&ageKeyFile, "sops-age-key-file", "", "File of age identities decrypting SOPS var files, instead of SOPS_AGE_KEY or SOPS_AGE_KEY_FILE")
+	flagSet.DurationVar(&execTimeout, "exec-timeout", kenv.DefaultExecTimeout, "Timeout of the commands of exec:// sources")
+	flagSet.StringVar(&logLevel, "log-level", "info", "Level of diagnostics written to STDERR: debug, info, warn or error")
+	flagSet.BoolVar(&toYAML, "yaml", false, "Output as YAML")
+	flagSet.Var(&varsFiles, "v", "Files or source URIs containing variables to inject as environment variables, optionally as container=file (repeatable)")
+	flagSet.Var(&secretFiles, "s", "Files or source URIs containing variables to inject as Secrets, optionally as container=file (repeatable)")
+	flagSet.Var(&configMapFiles, "c", "Files or source URIs containing variables to inject as ConfigMaps, optionally as container=file (repeatable)")
+	flagSet.Var(&containers, "container", "Only inject into containers matching a name, glob or /regex/ (repeatable)")
+	flagSet.StringVar(&targetContainerLists, "container-lists", "containers", "Comma separated PodSpec container lists to inject into: containers, initContainers, ephemeralContainers or all")
+	flagSet.Var(&selectNames, "select-name", "Only inject into resources with a matching name or glob (repeatable)")
+	flagSet.Var(&selectKinds, "select-kind", "Only inject into resources of a kind, optionally as apiVersion/Kind (repeatable)")
+	flagSet.StringVar(&selectLabels, "select-labels", "", "Only inject into resources matching a label selector, e.g. tier=backend,env!=dev")
+	flagSet.Var(&podPaths, "pod-path", "Inject into a custom kind at PodSpec or container list paths, e.g. Rollout=spec.template.spec (repeatable)")
+	flagSet.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] file\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, `Examples:
+
+  kenv -v fixtures/vars.env fixtures/deployment.yaml
+  kenv -name nginx -v fixtures/vars.env -s fixtures/secrets.yml fixtures/deployment.yaml
+  cat fixtures/deployment.yaml | kenv -v fixtures/vars.env
+  kenv -container nginx -v fixtures/vars.env -v sidecar=fixtures/plaintext.env fixtures/deployment-sidecar.yml
+  kenv -name nginx -env-from -c fixtures/configmap.env fixtures/deployment.yaml
+  kenv -name nginx -secret-mount /etc/nginx/secrets -s fixtures/secrets.yml fixtures/deployment.yaml
+  kenv -config-hash -v fixtures/vars.env fixtures/deployment.yaml
+  kenv -select-name worker -select-labels tier=backend -v fixtures/vars.env fixtures/workers.yml
+  kenv -v fixtures/vars.env -pod-path Rollout=spec.template.spec fixtures/rollout.yml
+  kenv -name nginx -s env://APP_?strip=true fixtures/deployment.yaml
+  kenv -name nginx -s vault://secret/data/nginx#password fixtures/deployment.yaml
+  kenv -name nginx -s "exec://./scripts/secrets.sh production" fixtures/deployment.yaml
+  kenv -name nginx-tls -secret-type tls -s fixtures/tls fixtures/deployment.yml
+  kenv -name nginx -sealed-secrets-cert fixtures/sealed-secrets.pem -s fixtures/secrets.yml fixtures/deployment.yml
+
+Options:
+`)
+		flagSet.PrintDefaults()
+	}
+}
+
+func main() {
+	var in *os.File
+	var err error
+
+	if err = flagSet.Parse(os.Args[1:]); err != nil {
+		kenv.Log.Fatal(err)
+	}
+
+	if kenv.Log.Level, err = kenv.ParseLogLevel(logLevel); err != nil {
+		kenv.Log.Fatal(err)
+	}
+
+	switch name := flagSet.Arg(0); {
+	case name == "":
+		fi, err := os.Stdin.Stat()
+		if err != nil {
+			kenv.Log.Fatal(err)
+		}
+		// Print usage unless we already have STDIN data or incoming pipe
+		if fi.Size() == 0 && fi.Mode()&os.ModeNamedPipe == 0 {
+			flagSet.Usage()
+			return
+		}
+		in = os.Stdin
+	default:
+		if in, err = os.Open(name); err != nil {
+			kenv.Log.Fatal(err)
+		}
+		defer in.Close()
+	}
+
+	config := kenv.Config{
+		Name:              name,
+		Namespace:         namespace,
+		ConvertKeys:       convertKeys,
+		VarsFiles:         varsFiles,
+		SecretFiles:       secretFiles,
+		ConfigMapFiles:    configMapFiles,
+		PodPaths:          podPaths,
+		ContainerLists:    targetContainerLists,
+		Containers:        containers,
+		SelectNames:       selectNames,
+		SelectKinds:       selectKinds,
+		SelectLabels:      selectLabels,
+		EnvFrom:           envFrom,
+		EnvFromPrefix:     envFromPrefix,
+		ConfigMapMount:    configMapMount,
+		SecretMount:       secretMount,
+		MountItems:        mountItems,
+		MountMode:         mountMode,
+		HashSuffix:        hashSuffix,
+		ConfigHash:        addConfigHash,
+		SecretType:        secretTypeName,
+		SealedSecretsCert: sealedSecretsCert,
+		SealingScope:      sealingScope,
+		YAML:              toYAML,
+		Vars: kenv.VarsOptions{
+			Strict:       strict,
+			KeySeparator: keySeparator,
+			UpperKeys:    upperKeys,
+			JSONLists:    jsonLists,
+			Interpolate:  interpolate || interpolateEnv,
+			EnvFallback:  interpolateEnv,
+			AgeKeyFile:   ageKeyFile,
+			ExecTimeout:  execTimeout,
+		},
+	}
+
+	if err = kenv.Run(config, in, os.Stdout); err != nil {
+		kenv.Log.Fatal(err)
+	}
+}
+
+// FlagSlice represents a repeatable string flag
+type FlagSlice []string
+
+// String returns a string representation of FlagSlice
+func (f *FlagSlice) String() string {
+	return strings.Join(*f, ",")
+}
+
+// Set appends a string value to FlagSlice
+func (f *FlagSlice) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
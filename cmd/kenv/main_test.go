@@ -10,8 +10,8 @@ func TestMainWithYAML(t *testing.T) {
 	os.Args = []string{
 		"kenv",
 		"-v",
-		"fixtures/vars.env",
-		"fixtures/deployment.yml",
+		"../../fixtures/vars.env",
+		"../../fixtures/deployment.yml",
 	}
 
 	main()
@@ -21,8 +21,8 @@ func TestMainWithJSON(t *testing.T) {
 	os.Args = []string{
 		"kenv",
 		"-v",
-		"fixtures/vars.env",
-		"fixtures/deployment.json",
+		"../../fixtures/vars.env",
+		"../../fixtures/deployment.json",
 	}
 
 	main()
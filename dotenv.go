@@ -0,0 +1,211 @@
+package kenv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dotenvParser parses docker/compose style .env data: comments, blank
+// lines, an optional "export " prefix, single quoted literal values, double
+// quoted values with escapes, and quoted values spanning multiple lines
+type dotenvParser struct {
+	filename string
+	src      string
+	pos      int
+	line     int
+	strict   bool
+}
+
+// parseDotenv parses .env data read from filename into Vars. Lines not in
+// key=value format are skipped with a warning, or reported as errors when
+// strict. Malformed values are always errors. Errors are prefixed with the
+// file and line number.
+func parseDotenv(filename string, data []byte, strict bool) (Vars, error) {
+	p := &dotenvParser{
+		filename: filename,
+		src:      strings.Replace(string(data), "\r\n", "\n", -1),
+		line:     1,
+		strict:   strict,
+	}
+
+	vars := Vars{}
+	for {
+		p.skipBlankAndComments()
+		if p.pos >= len(p.src) {
+			return vars, nil
+		}
+
+		v, ok, err := p.parseLine()
+		if err != nil {
+			return vars, err
+		}
+
+		if ok {
+			vars = append(vars, v)
+		}
+	}
+}
+
+// parseLine parses a single key=value assignment starting at the current
+// position, returning false when the line is skipped
+func (p *dotenvParser) parseLine() (Var, bool, error) {
+	start := p.line
+	rest := p.src[p.pos:]
+	end := strings.IndexByte(rest, '\n')
+	if end < 0 {
+		end = len(rest)
+	}
+
+	eq := strings.IndexByte(rest[:end], '=')
+	key := ""
+	if eq >= 0 {
+		key = strings.TrimSpace(rest[:eq])
+		if strings.HasPrefix(key, "export ") || strings.HasPrefix(key, "export\t") {
+			key = strings.TrimSpace(key[len("export"):])
+		}
+	}
+
+	if key == "" || strings.ContainsAny(key, " \t") {
+		if p.strict {
+			return Var{}, false, p.errorf(start, "not in key=value format")
+		}
+
+		Log.Warn("skipping line not in key=value format", "file", p.filename, "line", start)
+		p.pos += end
+		return Var{}, false, nil
+	}
+
+	p.pos += eq + 1
+	p.skipSpaces()
+
+	var value string
+	var err error
+	literal := false
+	if p.pos < len(p.src) && (p.src[p.pos] == '"' || p.src[p.pos] == '\'') {
+		// single quoted values are not interpolated
+		literal = p.src[p.pos] == '\''
+		value, err = p.parseQuoted()
+	} else {
+		value = p.parseUnquoted()
+	}
+
+	if err != nil {
+		return Var{}, false, err
+	}
+
+	v, err := decodeVar(Var{Key: key, Value: value, Literal: literal})
+	if err != nil {
+		return Var{}, false, p.errorf(start, "%s", err)
+	}
+
+	return v, true, nil
+}
+
+// parseQuoted parses a single or double quoted value, which may span lines
+func (p *dotenvParser) parseQuoted() (string, error) {
+	start := p.line
+	quote := p.src[p.pos]
+	p.pos++
+
+	value := []byte{}
+	for {
+		if p.pos >= len(p.src) {
+			return "", p.errorf(start, "unterminated quoted value")
+		}
+
+		c := p.src[p.pos]
+		p.pos++
+
+		switch {
+		case c == quote:
+			return string(value), p.endOfValue()
+		case c == '\n':
+			p.line++
+		case c == '\\' && quote == '"' && p.pos < len(p.src):
+			if escaped, ok := dotenvEscapes[p.src[p.pos]]; ok {
+				c = escaped
+				p.pos++
+			}
+		}
+
+		value = append(value, c)
+	}
+}
+
+// dotenvEscapes are the escape sequences of double quoted values
+var dotenvEscapes = map[byte]byte{
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+	'"':  '"',
+	'\\': '\\',
+}
+
+// parseUnquoted parses the rest of the line as a value, dropping a trailing
+// comment preceded by whitespace
+func (p *dotenvParser) parseUnquoted() string {
+	rest := p.src[p.pos:]
+	end := strings.IndexByte(rest, '\n')
+	if end < 0 {
+		end = len(rest)
+	}
+	p.pos += end
+
+	value := rest[:end]
+	for i := 1; i < len(value); i++ {
+		if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+			value = value[:i]
+			break
+		}
+	}
+
+	return strings.TrimSpace(value)
+}
+
+// endOfValue checks that only whitespace or a comment follows a quoted value
+func (p *dotenvParser) endOfValue() error {
+	p.skipSpaces()
+	if p.pos >= len(p.src) || p.src[p.pos] == '\n' || p.src[p.pos] == '#' {
+		p.skipLine()
+		return nil
+	}
+	return p.errorf(p.line, "unexpected characters after quoted value")
+}
+
+// skipBlankAndComments advances past blank lines and comment lines
+func (p *dotenvParser) skipBlankAndComments() {
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case ' ', '\t', '\r':
+			p.pos++
+		case '\n':
+			p.pos++
+			p.line++
+		case '#':
+			p.skipLine()
+		default:
+			return
+		}
+	}
+}
+
+// skipSpaces advances past spaces and tabs
+func (p *dotenvParser) skipSpaces() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// skipLine advances to the end of the current line
+func (p *dotenvParser) skipLine() {
+	if end := strings.IndexByte(p.src[p.pos:], '\n'); end >= 0 {
+		p.pos += end
+	} else {
+		p.pos = len(p.src)
+	}
+}
+
+// errorf returns an error prefixed with the file and line number
+func (p *dotenvParser) errorf(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", p.filename, line, fmt.Sprintf(format, args...))
+}
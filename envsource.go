@@ -0,0 +1,122 @@
+package kenv
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// EnvSource selects variables from the process environment, e.g.
+// env://APP_?strip=true&rename=DB_PASSWORD:DATABASE_PASSWORD
+type EnvSource struct {
+	// Prefix the names must start with
+	Prefix string
+	// Strip removes Prefix from the keys
+	Strip bool
+	// Regexp the names must match
+	Regexp *regexp.Regexp
+	// Renames maps keys, after stripping, to new keys
+	Renames map[string]string
+}
+
+// readEnvVars reads the process environment variables selected by an
+// env:// source
+func readEnvVars(value string, opts VarsOptions) (Vars, error) {
+	source, err := parseEnvSource(value)
+	if err != nil {
+		return Vars{}, err
+	}
+
+	vars := source.Vars(os.Environ())
+	if len(vars) == 0 {
+		Log.Warn("no environment variables matched", "source", value)
+	}
+
+	return vars, nil
+}
+
+// parseEnvSource parses an env://[PREFIX][?param&...] source. Supported
+// params are strip=true, regex=<regexp> and rename=<from>:<to>, which may
+// be repeated. Params are not URL decoded so regexps can be written as is.
+func parseEnvSource(value string) (*EnvSource, error) {
+	source := &EnvSource{Renames: map[string]string{}}
+
+	query := ""
+	source.Prefix = trimScheme(value)
+	if i := strings.Index(source.Prefix, "?"); i >= 0 {
+		source.Prefix, query = source.Prefix[:i], source.Prefix[i+1:]
+	}
+
+	for _, param := range strings.Split(query, "&") {
+		if param == "" {
+			continue
+		}
+
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%s: param %s must be in name=value format", value, param)
+		}
+
+		switch kv[0] {
+		case "strip":
+			source.Strip = kv[1] == "true"
+		case "regex":
+			re, err := regexp.Compile(kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", value, err)
+			}
+			source.Regexp = re
+		case "rename":
+			names := strings.SplitN(kv[1], ":", 2)
+			if len(names) != 2 || names[0] == "" || names[1] == "" {
+				return nil, fmt.Errorf("%s: rename %s must be in from:to format", value, kv[1])
+			}
+			source.Renames[names[0]] = names[1]
+		default:
+			return nil, fmt.Errorf("%s: unknown param %s", value, kv[0])
+		}
+	}
+
+	return source, nil
+}
+
+// Vars returns the selected variables of environ, a slice of "key=value"
+// strings as returned by os.Environ, sorted by name
+func (s *EnvSource) Vars(environ []string) Vars {
+	vars := Vars{}
+
+	sorted := append([]string{}, environ...)
+	sort.Strings(sorted)
+
+	for _, env := range sorted {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			continue
+		}
+
+		key := kv[0]
+		if !strings.HasPrefix(key, s.Prefix) {
+			continue
+		}
+		if s.Regexp != nil && !s.Regexp.MatchString(key) {
+			continue
+		}
+
+		if s.Strip {
+			key = strings.TrimPrefix(key, s.Prefix)
+		}
+		if to, ok := s.Renames[key]; ok {
+			key = to
+		}
+		if key == "" {
+			continue
+		}
+
+		// environment values are used verbatim
+		vars = append(vars, Var{Key: key, Value: kv[1], Literal: true})
+	}
+
+	return vars
+}
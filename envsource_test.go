@@ -0,0 +1,83 @@
+package kenv
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestEnvSourceVars(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin",
+		"APP_DB_HOST=db.example.com",
+		"APP_DB_PASSWORD=s3cr3t=",
+		"APP_DEBUG=true",
+		"OTHER_DB_HOST=other.example.com",
+	}
+
+	tests := map[string]Vars{
+		"env://APP_": Vars{
+			Var{Key: "APP_DB_HOST", Value: "db.example.com", Literal: true},
+			Var{Key: "APP_DB_PASSWORD", Value: "s3cr3t=", Literal: true},
+			Var{Key: "APP_DEBUG", Value: "true", Literal: true},
+		},
+		"env://APP_?strip=true&rename=DB_PASSWORD:DATABASE_PASSWORD": Vars{
+			Var{Key: "DB_HOST", Value: "db.example.com", Literal: true},
+			Var{Key: "DATABASE_PASSWORD", Value: "s3cr3t=", Literal: true},
+			Var{Key: "DEBUG", Value: "true", Literal: true},
+		},
+		"env://?regex=^[A-Z]+_DB_HOST$": Vars{
+			Var{Key: "APP_DB_HOST", Value: "db.example.com", Literal: true},
+			Var{Key: "OTHER_DB_HOST", Value: "other.example.com", Literal: true},
+		},
+	}
+
+	for value, want := range tests {
+		source, err := parseEnvSource(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		vars := source.Vars(environ)
+		if !reflect.DeepEqual(want, vars) {
+			t.Fatalf("%s: not equal, wanted: %+v, got: %+v", value, want, vars)
+		}
+	}
+}
+
+func TestParseEnvSourceErrors(t *testing.T) {
+	tests := []string{
+		"env://APP_?regex=(",
+		"env://APP_?rename=FOO",
+		"env://APP_?unknown=1",
+		"env://APP_?strip",
+	}
+
+	for _, value := range tests {
+		if _, err := parseEnvSource(value); err == nil {
+			t.Fatalf("expected error parsing %s", value)
+		}
+	}
+}
+
+func TestNewTargetedVarsFromEnv(t *testing.T) {
+	os.Setenv("KENV_TEST_TOKEN", "a$$b${q}")
+	defer os.Unsetenv("KENV_TEST_TOKEN")
+
+	// environment values are not interpolated
+	groups, err := newTargetedVarsFromFiles([]string{
+		"env://KENV_TEST_?strip=true",
+		"web=env://KENV_TEST_",
+	}, VarsOptions{Interpolate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []TargetedVars{
+		{Containers: ContainerSelector{}, Vars: Vars{Var{Key: "TOKEN", Value: "a$$b${q}", Literal: true}}},
+		{Containers: ContainerSelector{"web"}, Vars: Vars{Var{Key: "KENV_TEST_TOKEN", Value: "a$$b${q}", Literal: true}}},
+	}
+	if !reflect.DeepEqual(want, groups) {
+		t.Fatalf("not equal, wanted: %+v, got: %+v", want, groups)
+	}
+}
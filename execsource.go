@@ -0,0 +1,144 @@
+package kenv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultExecTimeout bounds exec:// commands when no timeout is set
+const DefaultExecTimeout = 30 * time.Second
+
+// readExecVars runs the command of an exec://command [args...][#format]
+// source and parses its stdout, in "key=value" format unless a format such
+// as #yaml or #json is given. The command is run without a shell, and a
+// non-zero exit, any stderr output or exceeding opts.ExecTimeout is an
+// error. The output is used verbatim, without interpolation.
+func readExecVars(uri string, opts VarsOptions) (Vars, error) {
+	command, formatName := splitExecFormat(trimScheme(uri))
+	format, _ := LookupVarFormat(formatName)
+
+	args, err := splitCommand(command)
+	if err != nil {
+		return Vars{}, fmt.Errorf("%s: %s", uri, err)
+	}
+	if len(args) == 0 {
+		return Vars{}, fmt.Errorf("%s: missing command", uri)
+	}
+
+	timeout := opts.ExecTimeout
+	if timeout <= 0 {
+		timeout = DefaultExecTimeout
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	// the command runs in its own process group, so processes it starts are
+	// killed with it rather than keeping its output open
+	setProcessGroup(cmd)
+	if err = cmd.Start(); err != nil {
+		return Vars{}, fmt.Errorf("%s: %s", uri, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err = <-done:
+	case <-timer.C:
+		killProcessGroup(cmd)
+		<-done
+		return Vars{}, fmt.Errorf("%s: timed out after %s", uri, timeout)
+	}
+
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return Vars{}, fmt.Errorf("%s: %s: %s", uri, err, msg)
+		}
+		return Vars{}, fmt.Errorf("%s: %s", uri, err)
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return Vars{}, fmt.Errorf("%s: wrote to stderr: %s", uri, msg)
+	}
+
+	vars, err := format.ParseVars(uri, stdout.Bytes(), opts)
+	if err != nil {
+		return vars, err
+	}
+
+	for i := range vars {
+		vars[i].Literal = true
+	}
+	return vars, nil
+}
+
+// splitExecFormat splits a trailing #format from a command when it names a
+// registered VarFormat and the # is not quoted, so commands may still pass
+// arguments such as op://vault/item#field
+func splitExecFormat(command string) (string, string) {
+	i := strings.LastIndex(command, "#")
+	if i < 0 {
+		return command, defaultVarFormat
+	}
+
+	if _, ok := LookupVarFormat(command[i+1:]); !ok {
+		return command, defaultVarFormat
+	}
+
+	// an unterminated quote before the # means it is quoted
+	if _, err := splitCommand(command[:i]); err != nil {
+		return command, defaultVarFormat
+	}
+
+	return command[:i], command[i+1:]
+}
+
+// splitCommand splits a command line into arguments on spaces, keeping
+// single or double quoted arguments together. Backslash escapes are not
+// supported.
+func splitCommand(command string) ([]string, error) {
+	args := []string{}
+	arg := ""
+	inArg := false
+	var quote rune
+
+	for _, c := range command {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			arg += string(c)
+		case c == '\'' || c == '"':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, arg)
+				arg, inArg = "", false
+			}
+		default:
+			arg += string(c)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return args, errors.New("unterminated quote")
+	}
+	if inArg {
+		args = append(args, arg)
+	}
+
+	return args, nil
+}
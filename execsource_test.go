@@ -0,0 +1,87 @@
+package kenv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadExecVars(t *testing.T) {
+	tests := map[string]Vars{
+		`exec://printf 'DB_HOST=db.example.com\nDB_PASSWORD=s3cr3t\n'`: Vars{
+			Var{Key: "DB_HOST", Value: "db.example.com", Literal: true},
+			Var{Key: "DB_PASSWORD", Value: "s3cr3t", Literal: true},
+		},
+		`exec://printf 'db:\n  host: db.example.com\n'#yaml`: Vars{
+			Var{Key: "db_host", Value: "db.example.com", Literal: true},
+		},
+		`exec://echo '{"db": {"port": 5432}}'#json`: Vars{
+			Var{Key: "db_port", Value: "5432", Literal: true},
+		},
+		// only a trailing, unquoted #format is split from the command
+		`exec://echo 'A=x#yaml'`: Vars{
+			Var{Key: "A", Value: "x#yaml", Literal: true},
+		},
+		`exec://echo A=op://vault/item#field`: Vars{
+			Var{Key: "A", Value: "op://vault/item#field", Literal: true},
+		},
+		`exec://echo 'A=p${q}$$'`: Vars{
+			Var{Key: "A", Value: "p${q}$$", Literal: true},
+		},
+	}
+
+	for uri, want := range tests {
+		vars, err := readVarsFile(uri, VarsOptions{Interpolate: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(vars, want) {
+			t.Fatalf("%s: expected %v, got %v", uri, want, vars)
+		}
+	}
+}
+
+func TestReadExecVarsErrors(t *testing.T) {
+	tests := map[string]string{
+		`exec://sh -c 'echo KEY=value; exit 3'`:        "exit status 3",
+		`exec://sh -c 'echo denied >&2; exit 1'`:       "exit status 1: denied",
+		`exec://sh -c 'echo KEY=value; echo oops >&2'`: "wrote to stderr: oops",
+		`exec://sleep 5`:              "timed out after 100ms",
+		`exec://echo 'KEY=value`:      "unterminated quote",
+		`exec://`:                     "missing command",
+		`exec://kenv-missing-command`: "executable file not found",
+	}
+
+	opts := VarsOptions{ExecTimeout: 100 * time.Millisecond}
+	for uri, want := range tests {
+		if _, err := readVarsFile(uri, opts); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected error containing %q, got %v", uri, want, err)
+		}
+	}
+}
+
+func TestReadExecVarsTimeoutKillsChildren(t *testing.T) {
+	// the shell's children keep stdout open unless they are killed too
+	start := time.Now()
+	_, err := readVarsFile(`exec://sh -c 'sleep 4; echo A=1'`, VarsOptions{ExecTimeout: 500 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "timed out after 500ms") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("expected the command to be killed after 500ms, took %s", elapsed)
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	args, err := splitCommand(`op read  "op://vault/app/db password" --no-newline 'a b'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"op", "read", "op://vault/app/db password", "--no-newline", "a b"}
+	if !reflect.DeepEqual(args, want) {
+		t.Fatalf("Expected %q, got %q", want, args)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+package kenv
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup does nothing on Windows, where only cmd is killed
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the process of cmd
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,107 @@
+package kenv
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// readFileVars reads a file://path source, or a plain path, as a file in
+// the format of its extension, every file of a directory, or every file
+// matching a glob
+func readFileVars(uri string, opts VarsOptions) (Vars, error) {
+	filename := trimScheme(uri)
+
+	info, err := os.Stat(filename)
+	if err != nil && isGlob(filename) {
+		return readGlobVars(filename, opts)
+	}
+	if err == nil && info.IsDir() {
+		return readDirVars(filename)
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return Vars{}, err
+	}
+
+	return varFormatOf(filename).ParseVars(filename, data, opts)
+}
+
+// isGlob returns true if filename contains glob metacharacters
+func isGlob(filename string) bool {
+	return strings.ContainsAny(filename, "*?[")
+}
+
+// readGlobVars reads the vars of every file or directory matching pattern,
+// in name order
+func readGlobVars(pattern string, opts VarsOptions) (Vars, error) {
+	vars := Vars{}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return vars, fmt.Errorf("%s: %s", pattern, err)
+	}
+	if len(matches) == 0 {
+		return vars, fmt.Errorf("%s matched no files", pattern)
+	}
+
+	for _, filename := range matches {
+		v, err := readFileVars(filename, opts)
+		if err != nil {
+			return vars, err
+		}
+
+		vars = append(vars, v...)
+	}
+
+	return vars, nil
+}
+
+// readDirVars reads every regular file of a directory as a var, like
+// kubectl create configmap --from-file. The file name is the key and its
+// contents the literal value, marked Binary when not valid UTF-8.
+// Subdirectories and other special files are skipped.
+func readDirVars(dirname string) (Vars, error) {
+	vars := Vars{}
+
+	files, err := ioutil.ReadDir(dirname)
+	if err != nil {
+		return vars, err
+	}
+
+	for _, file := range files {
+		filename := filepath.Join(dirname, file.Name())
+
+		// follow symlinks, as used by mounted ConfigMaps and Secrets
+		info, err := os.Stat(filename)
+		if err != nil {
+			return vars, err
+		}
+		if !info.Mode().IsRegular() {
+			Log.Debug("skipping non-regular file", "file", filename)
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return vars, err
+		}
+
+		vars = append(vars, Var{
+			Key:     file.Name(),
+			Value:   string(data),
+			Binary:  !utf8.Valid(data),
+			Literal: true,
+		})
+	}
+
+	if len(vars) == 0 {
+		Log.Warn("no files found", "dir", dirname)
+	}
+
+	return vars, nil
+}
@@ -0,0 +1,77 @@
+package kenv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadDirVars(t *testing.T) {
+	want := Vars{
+		Var{Key: "keystore.jks", Value: "\xfe\xed\xfe\xed\x00\x00\x00\x02", Binary: true, Literal: true},
+		Var{Key: "nginx.conf", Value: "server {\n  listen 80;\n  set $$x ${y};\n}\n", Literal: true},
+	}
+
+	// literal values are not interpolated
+	groups, err := newTargetedVarsFromFiles([]string{"fixtures/configdir"}, VarsOptions{Interpolate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars := joinTargetedVars(groups)
+	if !reflect.DeepEqual(want, vars) {
+		t.Fatalf("not equal, wanted: %+v, got: %+v", want, vars)
+	}
+}
+
+func TestReadGlobVars(t *testing.T) {
+	vars, err := readVarsFile("fixtures/vars.*", VarsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{}
+	for _, v := range vars {
+		keys = append(keys, v.Key)
+	}
+
+	want := "KVKey1,kvkey2,YAMLKey1,yamlkey2"
+	if strings.Join(keys, ",") != want {
+		t.Fatalf("expected keys %s, got %s", want, strings.Join(keys, ","))
+	}
+
+	if _, err := readVarsFile("fixtures/missing-*.env", VarsOptions{}); err == nil {
+		t.Fatal("expected error for a glob matching no files")
+	}
+}
+
+func TestBinaryVars(t *testing.T) {
+	vars := Vars{
+		Var{Key: "text", Value: "hello"},
+		Var{Key: "blob", Value: "\xff\x00", Binary: true},
+	}
+
+	envVars, configMap, err := vars.toConfigMap("foo", "bar", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(envVars) != 1 || envVars[0].Name != "text" {
+		t.Fatalf("expected only the text key as env var, got %+v", envVars)
+	}
+	if configMap.Data["text"] != "hello" || string(configMap.BinaryData["blob"]) != "\xff\x00" {
+		t.Fatalf("unexpected ConfigMap data: %+v", configMap)
+	}
+
+	_, secret, err := vars.toSecret("foo", "bar", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(secret.Data["blob"]) != "\xff\x00" {
+		t.Fatalf("expected raw secret bytes, got %v", secret.Data["blob"])
+	}
+
+	if _, err := vars.toEnvVar(); err == nil {
+		t.Fatal("expected error injecting binary as plaintext")
+	}
+}
@@ -0,0 +1,224 @@
+package kenv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultKeySeparator joins nested keys when no separator is set
+const DefaultKeySeparator = "_"
+
+// parseStructuredVars decodes a JSON object read from filename, decrypting
+// it when SOPS encrypted, and flattens it into Vars. Nested keys are joined
+// with the key separator, so {"db": {"host": "x"}} becomes db_host=x.
+// Scalars are stringified as written: numbers keep their literal form,
+// booleans become true/false and null becomes an empty value.
+func parseStructuredVars(filename string, data []byte, opts VarsOptions) (Vars, error) {
+	config, err := decodeJSON(data)
+	if err == nil && isSOPSTree(config) {
+		if data, err = decryptSOPS(data, "json", opts); err == nil {
+			config, err = decodeJSON(data)
+		}
+	}
+	if err != nil {
+		return Vars{}, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	return flattenTree(filename, config, opts)
+}
+
+// parseYAMLVars decodes a YAML document read from filename and flattens it
+// like parseStructuredVars. Scalars keep the text they were written with, so
+// version: 1.10 and mode: 0755 are not read as the numbers 1.1 and 493.
+func parseYAMLVars(filename string, data []byte, opts VarsOptions) (Vars, error) {
+	config, err := decodeYAML(data)
+	if err == nil && isSOPSTree(config) {
+		if data, err = decryptSOPS(data, "yaml", opts); err == nil {
+			config, err = decodeYAML(data)
+		}
+	}
+	if err != nil {
+		return Vars{}, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	return flattenTree(filename, config, opts)
+}
+
+// decodeJSON decodes a JSON document, keeping numbers as written
+func decodeJSON(data []byte) (interface{}, error) {
+	var config interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&config)
+	return config, err
+}
+
+// decodeYAML decodes a YAML document, keeping scalars as written
+func decodeYAML(data []byte) (interface{}, error) {
+	node := yamlNode{}
+	err := yaml.Unmarshal(data, &node)
+	return node.value, err
+}
+
+// flattenTree flattens a decoded YAML or JSON document into Vars
+func flattenTree(filename string, config interface{}, opts VarsOptions) (Vars, error) {
+	vars := Vars{}
+
+	// an empty YAML document decodes to null
+	if config == nil {
+		return vars, nil
+	}
+
+	if _, ok := config.(map[string]interface{}); !ok {
+		return vars, fmt.Errorf("%s: must be a map of keys to values", filename)
+	}
+
+	if err := flattenVars(&vars, "", config, opts); err != nil {
+		return vars, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	for i := range vars {
+		v, err := decodeVar(vars[i])
+		if err != nil {
+			return vars, fmt.Errorf("%s: %s", filename, err)
+		}
+		vars[i] = v
+	}
+
+	return vars, nil
+}
+
+// flattenVars appends the Vars of value under key, recursing into maps in
+// sorted key order and lists in index order
+func flattenVars(vars *Vars, key string, value interface{}, opts VarsOptions) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(v) {
+			if err := flattenVars(vars, joinKey(key, k, opts), v[k], opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		if opts.JSONLists {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			*vars = append(*vars, Var{Key: key, Value: string(data)})
+			return nil
+		}
+		for i, item := range v {
+			if err := flattenVars(vars, joinKey(key, strconv.Itoa(i), opts), item, opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	*vars = append(*vars, Var{Key: key, Value: scalarString(value)})
+	return nil
+}
+
+// joinKey appends key to prefix with the configured separator and case
+func joinKey(prefix, key string, opts VarsOptions) string {
+	if opts.UpperKeys {
+		key = strings.ToUpper(key)
+	}
+	if prefix == "" {
+		return key
+	}
+
+	separator := opts.KeySeparator
+	if separator == "" {
+		separator = DefaultKeySeparator
+	}
+	return prefix + separator + key
+}
+
+// scalarString stringifies a decoded JSON scalar
+func scalarString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case yamlScalar:
+		return string(v)
+	}
+	return fmt.Sprint(value)
+}
+
+// yamlNode decodes a YAML value into maps, lists and scalars. Strings are
+// kept as strings and other scalars as yamlScalars.
+type yamlNode struct {
+	value interface{}
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (n *yamlNode) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value interface{}
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+
+	switch value.(type) {
+	case nil:
+		n.value = nil
+	case string:
+		n.value = value
+	case map[interface{}]interface{}:
+		nodes := map[string]yamlNode{}
+		if err := unmarshal(&nodes); err != nil {
+			return err
+		}
+		m := map[string]interface{}{}
+		for k, node := range nodes {
+			m[k] = node.value
+		}
+		n.value = m
+	case []interface{}:
+		nodes := []yamlNode{}
+		if err := unmarshal(&nodes); err != nil {
+			return err
+		}
+		l := make([]interface{}, len(nodes))
+		for i, node := range nodes {
+			l[i] = node.value
+		}
+		n.value = l
+	default:
+		// decoding into a string keeps the scalar's text
+		var text string
+		if err := unmarshal(&text); err != nil {
+			return err
+		}
+		n.value = yamlScalar(text)
+	}
+
+	return nil
+}
+
+// yamlScalar is a YAML number or boolean as written
+type yamlScalar string
+
+// MarshalJSON writes the scalar as a JSON literal when its text is one,
+// such as 5432 or true, and as a JSON string otherwise
+func (s yamlScalar) MarshalJSON() ([]byte, error) {
+	var literal interface{}
+	if err := json.Unmarshal([]byte(s), &literal); err == nil {
+		if _, ok := literal.(string); !ok {
+			return []byte(s), nil
+		}
+	}
+	return json.Marshal(string(s))
+}
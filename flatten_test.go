@@ -0,0 +1,92 @@
+package kenv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadNestedVars(t *testing.T) {
+	want := Vars{
+		Var{Key: "db_host", Value: "db.example.com"},
+		Var{Key: "db_port", Value: "5432"},
+		Var{Key: "db_replicas_0", Value: "db-1.example.com"},
+		Var{Key: "db_replicas_1", Value: "db-2.example.com"},
+		Var{Key: "debug", Value: "true"},
+		Var{Key: "empty", Value: ""},
+		Var{Key: "timeout", Value: "1.5"},
+	}
+
+	for _, filename := range []string{"fixtures/nested.yaml", "fixtures/nested.json"} {
+		vars, err := readVarsFile(filename, VarsOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(want, vars) {
+			t.Fatalf("%s: not equal, wanted: %+v, got: %+v", filename, want, vars)
+		}
+	}
+}
+
+func TestReadNestedVarsOptions(t *testing.T) {
+	want := Vars{
+		Var{Key: "DB.HOST", Value: "db.example.com"},
+		Var{Key: "DB.PORT", Value: "5432"},
+		Var{Key: "DB.REPLICAS", Value: `["db-1.example.com","db-2.example.com"]`},
+		Var{Key: "DEBUG", Value: "true"},
+		Var{Key: "EMPTY", Value: ""},
+		Var{Key: "TIMEOUT", Value: "1.5"},
+	}
+
+	opts := VarsOptions{KeySeparator: ".", UpperKeys: true, JSONLists: true}
+
+	vars, err := readVarsFile("fixtures/nested.yaml", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(want, vars) {
+		t.Fatalf("not equal, wanted: %+v, got: %+v", want, vars)
+	}
+}
+
+func TestParseStructuredVarsErrors(t *testing.T) {
+	tests := []string{
+		`["not", "a", "map"]`,
+		`{"unterminated": `,
+	}
+
+	for _, data := range tests {
+		if _, err := parseStructuredVars("test.json", []byte(data), VarsOptions{}); err == nil {
+			t.Fatalf("expected error parsing %s", data)
+		}
+	}
+}
+
+func TestParseYAMLVarsScalars(t *testing.T) {
+	data := `
+version: 1.10
+mode: 0755
+big: 12345678901234567890
+flag: yes
+hex: 0x1F
+list: [1.10, 0755, yes, 5432]
+`
+	want := Vars{
+		Var{Key: "big", Value: "12345678901234567890"},
+		Var{Key: "flag", Value: "yes"},
+		Var{Key: "hex", Value: "0x1F"},
+		Var{Key: "list", Value: `[1.10,"0755","yes",5432]`},
+		Var{Key: "mode", Value: "0755"},
+		Var{Key: "version", Value: "1.10"},
+	}
+
+	vars, err := parseYAMLVars("test.yaml", []byte(data), VarsOptions{JSONLists: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(want, vars) {
+		t.Fatalf("not equal, wanted: %+v, got: %+v", want, vars)
+	}
+}
@@ -0,0 +1,128 @@
+package kenv
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// configHashAnnotation is the pod template annotation holding configHash
+const configHashAnnotation = "kenv/config-hash"
+
+// hashSuffixRegexp matches the suffix appended by hashedName
+var hashSuffixRegexp = regexp.MustCompile(`^-[0-9a-f]{10}$`)
+
+// hashedName appends a deterministic hash of a ConfigMap or Secret to its
+// name, so changing its data changes the name referenced by the pod template
+func hashedName(name string, resource interface{}) (string, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return name + "-" + hex.EncodeToString(sum[:])[:10], nil
+}
+
+// configHash returns a digest of the injected vars keyed by their source,
+// e.g. plaintext, ConfigMap or Secret. Each group's container selector is
+// part of the digest, so retargeting a file also changes it.
+func configHash(groups map[string][]TargetedVars) (string, error) {
+	// JSON replaces invalid UTF-8, so binary values are hashed as base64
+	encoded := map[string][]TargetedVars{}
+	for source, g := range groups {
+		encoded[source] = make([]TargetedVars, len(g))
+		for n := range g {
+			v := make(Vars, len(g[n].Vars))
+			for i := range g[n].Vars {
+				v[i] = g[n].Vars[i]
+				if v[i].Binary {
+					v[i].Value = base64.StdEncoding.EncodeToString([]byte(v[i].Value))
+				}
+			}
+			encoded[source][n] = TargetedVars{Containers: g[n].Containers, Vars: v}
+		}
+	}
+
+	data, err := json.Marshal(encoded)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ResourceRename renames the references to a ConfigMap or Secret, including
+// those to an earlier hashedName of it
+type ResourceRename struct {
+	Kind    string
+	Name    string
+	NewName string
+}
+
+// matches checks whether a referenced name is the renamed resource
+func (r ResourceRename) matches(name string) bool {
+	return name == r.Name || (strings.HasPrefix(name, r.Name) && hashSuffixRegexp.MatchString(name[len(r.Name):]))
+}
+
+// renameRef renames the name field of an unstructured reference
+func (r ResourceRename) renameRef(ref interface{}, field string) {
+	m, ok := ref.(map[string]interface{})
+	if ok && r.matches(fieldString(m, field)) {
+		m[field] = r.NewName
+	}
+}
+
+// renameContainerRefs renames the configMapKeyRef/secretKeyRef env entries
+// and configMapRef/secretRef envFrom entries of an unstructured container
+func (r ResourceRename) renameContainerRefs(container map[string]interface{}) {
+	keyRef, ref := "configMapKeyRef", "configMapRef"
+	if r.Kind == "Secret" {
+		keyRef, ref = "secretKeyRef", "secretRef"
+	}
+
+	env, _ := container["env"].([]interface{})
+	for _, e := range env {
+		entry, _ := e.(map[string]interface{})
+		valueFrom, _ := entry["valueFrom"].(map[string]interface{})
+		r.renameRef(valueFrom[keyRef], "name")
+	}
+
+	envFrom, _ := container["envFrom"].([]interface{})
+	for _, e := range envFrom {
+		source, _ := e.(map[string]interface{})
+		r.renameRef(source[ref], "name")
+	}
+}
+
+// renameVolumeRefs renames the configMap/secret volumes of an unstructured
+// PodSpec
+func (r ResourceRename) renameVolumeRefs(podSpec map[string]interface{}) {
+	source, field := "configMap", "name"
+	if r.Kind == "Secret" {
+		source, field = "secret", "secretName"
+	}
+
+	volumes, _ := podSpec["volumes"].([]interface{})
+	for _, v := range volumes {
+		volume, _ := v.(map[string]interface{})
+		r.renameRef(volume[source], field)
+	}
+}
+
+// renameImagePullSecretRefs renames the imagePullSecrets of an unstructured
+// PodSpec
+func (r ResourceRename) renameImagePullSecretRefs(podSpec map[string]interface{}) {
+	if r.Kind != "Secret" {
+		return
+	}
+
+	secrets, _ := podSpec["imagePullSecrets"].([]interface{})
+	for _, s := range secrets {
+		r.renameRef(s, "name")
+	}
+}
@@ -0,0 +1,191 @@
+package kenv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashedName(t *testing.T) {
+	_, configMap, err := Vars{
+		Var{
+			Key:   "key1",
+			Value: "value1",
+		},
+	}.toConfigMap("nginx", "default", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, err := hashedName("nginx", configMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := hashedName("nginx", configMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name != again {
+		t.Fatalf("hashed name not deterministic: %s != %s", name, again)
+	}
+
+	if !strings.HasPrefix(name, "nginx-") || !hashSuffixRegexp.MatchString(name[len("nginx"):]) {
+		t.Fatalf("unexpected hashed name: %s", name)
+	}
+
+	configMap.Data["key1"] = "value2"
+	changed, err := hashedName("nginx", configMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changed == name {
+		t.Fatalf("hashed name did not change with the data")
+	}
+}
+
+func TestResourceRenameMatches(t *testing.T) {
+	r := ResourceRename{Kind: "ConfigMap", Name: "nginx", NewName: "nginx-fedcba9876"}
+
+	for name, want := range map[string]bool{
+		"nginx":             true,
+		"nginx-0123456789":  true,
+		"nginx-shared":      false,
+		"nginx-0123456789a": false,
+		"other":             false,
+	} {
+		if got := r.matches(name); got != want {
+			t.Fatalf("matching %s: want %t, got %t", name, want, got)
+		}
+	}
+}
+
+func TestInjectionRenames(t *testing.T) {
+	injection := &Injection{
+		Renames: []ResourceRename{
+			ResourceRename{Kind: "ConfigMap", Name: "nginx", NewName: "nginx-fedcba9876"},
+		},
+	}
+
+	doc := injectFixture(t, "fixtures/deployment-refs.yml", injection)
+
+	resource := &podTemplateResource{}
+	if err := remarshal(doc, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	podSpec := resource.Spec.Template.Spec
+	env := podSpec.Containers[0].Env
+	if env[0].ValueFrom.ConfigMapKeyRef.Name != "nginx-fedcba9876" {
+		t.Fatalf("configMapKeyRef not renamed: %+v", env[0].ValueFrom.ConfigMapKeyRef)
+	}
+
+	if env[1].ValueFrom.ConfigMapKeyRef.Name != "nginx-shared" {
+		t.Fatalf("unrelated configMapKeyRef renamed: %+v", env[1].ValueFrom.ConfigMapKeyRef)
+	}
+
+	if env[2].ValueFrom.SecretKeyRef.Name != "nginx" {
+		t.Fatalf("secretKeyRef renamed: %+v", env[2].ValueFrom.SecretKeyRef)
+	}
+
+	if podSpec.Volumes[0].ConfigMap.Name != "nginx-fedcba9876" {
+		t.Fatalf("volume not renamed: %+v", podSpec.Volumes[0].ConfigMap)
+	}
+
+	envFrom := findPath(doc, []string{"spec", "template", "spec", "initContainers", "*", "envFrom", "*", "configMapRef", "name"})
+	if len(envFrom) != 1 || envFrom[0] != "nginx-fedcba9876" {
+		t.Fatalf("envFrom not renamed: %+v", envFrom)
+	}
+}
+
+func TestRenameContainerRefsMalformed(t *testing.T) {
+	r := ResourceRename{Kind: "ConfigMap", Name: "nginx", NewName: "nginx-fedcba9876"}
+
+	ref := map[string]interface{}{"name": "nginx"}
+	container := map[string]interface{}{
+		"env": []interface{}{
+			nil,
+			"KEY=value",
+			map[string]interface{}{"valueFrom": map[string]interface{}{"configMapKeyRef": ref}},
+		},
+		"envFrom": []interface{}{nil},
+	}
+
+	r.renameContainerRefs(container)
+
+	if ref["name"] != "nginx-fedcba9876" {
+		t.Fatalf("configMapKeyRef not renamed after malformed env entries: %+v", ref)
+	}
+}
+
+func TestConfigHash(t *testing.T) {
+	groups := []TargetedVars{
+		TargetedVars{
+			Containers: ContainerSelector{},
+			Vars: Vars{
+				Var{
+					Key:   "key1",
+					Value: "value1",
+				},
+			},
+		},
+	}
+
+	plaintext, err := configHash(map[string][]TargetedVars{"plaintext": groups})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := configHash(map[string][]TargetedVars{"secret": groups})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plaintext == secret {
+		t.Fatalf("config hash should depend on the var source")
+	}
+
+	again, err := configHash(map[string][]TargetedVars{"plaintext": groups})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plaintext != again {
+		t.Fatalf("config hash not deterministic")
+	}
+
+	// moving the file to another container changes the hash
+	targeted := []TargetedVars{
+		TargetedVars{
+			Containers: ContainerSelector{"sidecar"},
+			Vars:       groups[0].Vars,
+		},
+	}
+
+	moved, err := configHash(map[string][]TargetedVars{"plaintext": targeted})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plaintext == moved {
+		t.Fatalf("config hash should depend on the targeted containers")
+	}
+}
+
+func TestInjectionAnnotations(t *testing.T) {
+	injection := &Injection{
+		Annotations: map[string]string{configHashAnnotation: "abc"},
+	}
+
+	for filename, path := range map[string][]string{
+		"fixtures/deployment.json": []string{"spec", "template", "metadata", "annotations", configHashAnnotation},
+		"fixtures/cronjob.json":    []string{"spec", "jobTemplate", "spec", "template", "metadata", "annotations", configHashAnnotation},
+		"fixtures/pod.json":        []string{"metadata", "annotations", configHashAnnotation},
+	} {
+		doc := injectFixture(t, filename, injection)
+		if got := findPath(doc, path); len(got) != 1 || got[0] != "abc" {
+			t.Fatalf("%s not annotated: %+v", filename, doc)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package kenv
+
+import (
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// httpTimeout bounds each request of an http:// source
+const httpTimeout = 30 * time.Second
+
+// httpContentTypes maps response content types to VarFormat names
+var httpContentTypes = map[string]string{
+	"application/json":   "json",
+	"application/yaml":   "yaml",
+	"application/x-yaml": "yaml",
+	"text/yaml":          "yaml",
+	"text/x-yaml":        "yaml",
+}
+
+// HTTPSource reads a vars file from an http:// or https:// URL
+type HTTPSource struct {
+	Client *http.Client
+}
+
+// newHTTPSource returns an HTTPSource with a request timeout
+func newHTTPSource() *HTTPSource {
+	return &HTTPSource{Client: &http.Client{Timeout: httpTimeout}}
+}
+
+// ReadVars fetches a URL and parses the response in the format of its
+// content type, falling back to the extension of the URL path
+func (s *HTTPSource) ReadVars(uri string, opts VarsOptions) (Vars, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return Vars{}, err
+	}
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: httpTimeout}
+	}
+
+	resp, err := client.Get(uri)
+	if err != nil {
+		return Vars{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Vars{}, fmt.Errorf("%s returned %s", redactURL(u), resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Vars{}, err
+	}
+
+	format := varFormatOf(u.Path)
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if name, ok := httpContentTypes[mediaType]; ok {
+		format, _ = LookupVarFormat(name)
+	}
+
+	return format.ParseVars(redactURL(u), data, opts)
+}
+
+// redactURL hides the password and query of a URL, which may hold
+// credentials, for errors
+func redactURL(u *url.URL) string {
+	redacted := *u
+	if _, ok := redacted.User.Password(); ok {
+		redacted.User = url.UserPassword(redacted.User.Username(), "xxxxx")
+	}
+	if redacted.RawQuery != "" {
+		redacted.RawQuery = "xxxxx"
+	}
+	return redacted.String()
+}
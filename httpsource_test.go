@@ -0,0 +1,64 @@
+package kenv
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTTPSourceReadVars(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/vars":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			fmt.Fprint(w, `{"db":{"host":"db.example.com","port":5432}}`)
+		case "/vars.yaml":
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, "db:\n  host: db.example.com\n  port: 5432\n")
+		case "/vars.env":
+			fmt.Fprint(w, "DB_HOST=db.example.com\nDB_PORT=5432\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	want := Vars{
+		Var{Key: "db_host", Value: "db.example.com"},
+		Var{Key: "db_port", Value: "5432"},
+	}
+
+	source := newHTTPSource()
+	for _, p := range []string{"/vars", "/vars.yaml"} {
+		vars, err := source.ReadVars(server.URL+p, VarsOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(vars, want) {
+			t.Fatalf("%s: expected %v, got %v", p, want, vars)
+		}
+	}
+
+	vars, err := readVarsFile(server.URL+"/vars.env", VarsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = Vars{
+		Var{Key: "DB_HOST", Value: "db.example.com"},
+		Var{Key: "DB_PORT", Value: "5432"},
+	}
+	if !reflect.DeepEqual(vars, want) {
+		t.Fatalf("Expected %v, got %v", want, vars)
+	}
+
+	_, err = source.ReadVars(server.URL+"/missing?token=s3cr3t", VarsOptions{})
+	if err == nil || !strings.Contains(err.Error(), "404 Not Found") {
+		t.Fatalf("Expected a 404 error, got %v", err)
+	}
+	if strings.Contains(err.Error(), "s3cr3t") {
+		t.Fatalf("Expected the query to be redacted, got %v", err)
+	}
+}
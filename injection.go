@@ -0,0 +1,379 @@
+package kenv
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"k8s.io/kubernetes/pkg/api/v1"
+)
+
+// containerLists are the PodSpec fields holding containers
+var containerLists = []string{
+	"containers",
+	"initContainers",
+	"ephemeralContainers",
+}
+
+// Injection describes what is injected into each PodSpec of a resource
+type Injection struct {
+	EnvVars      []v1.EnvVar
+	EnvFrom      []EnvFromSource
+	VolumeMounts []v1.VolumeMount
+
+	// Volumes are added to the PodSpecs where a container mounts them,
+	// replacing volumes of the same name and source
+	Volumes []v1.Volume
+
+	// ImagePullSecrets are added to the PodSpec unless already referenced
+	ImagePullSecrets []v1.LocalObjectReference
+
+	// Containers selects the containers receiving EnvVars, EnvFrom and
+	// VolumeMounts
+	Containers ContainerSelector
+
+	// ContainerInjections are injected into the containers matching their
+	// own selector, taking precedence over the untargeted fields
+	ContainerInjections []ContainerInjection
+
+	// ContainerLists names the PodSpec container lists to inject into,
+	// only "containers" when empty
+	ContainerLists []string
+
+	// Annotations are added to the metadata of each pod template
+	Annotations map[string]string
+
+	// Renames are applied to the ConfigMap and Secret references of every
+	// container and volume before injecting
+	Renames []ResourceRename
+}
+
+// ContainerInjection holds EnvVars, EnvFrom and VolumeMounts targeted at
+// specific containers
+type ContainerInjection struct {
+	Containers   ContainerSelector
+	EnvVars      []v1.EnvVar
+	EnvFrom      []EnvFromSource
+	VolumeMounts []v1.VolumeMount
+}
+
+// EnvFromSource is a container envFrom entry, missing from the vendored
+// v1 types
+type EnvFromSource struct {
+	Prefix       string                   `json:"prefix,omitempty"`
+	ConfigMapRef *v1.LocalObjectReference `json:"configMapRef,omitempty"`
+	SecretRef    *v1.LocalObjectReference `json:"secretRef,omitempty"`
+}
+
+// ContainerSelector matches container names against a list of names, globs
+// or /regex/ patterns. An empty ContainerSelector matches every container.
+type ContainerSelector []string
+
+// addEnvVars adds EnvVars for the selected containers, or for every
+// container matching the Injection selector when containers is empty
+func (i *Injection) addEnvVars(containers ContainerSelector, envVars []v1.EnvVar) {
+	if len(containers) == 0 {
+		i.EnvVars = append(i.EnvVars, envVars...)
+		return
+	}
+
+	i.ContainerInjections = append(i.ContainerInjections, ContainerInjection{
+		Containers: containers,
+		EnvVars:    envVars,
+	})
+}
+
+// addEnvFrom adds an envFrom entry for the selected containers, or for every
+// container matching the Injection selector when containers is empty
+func (i *Injection) addEnvFrom(containers ContainerSelector, source EnvFromSource) {
+	if len(containers) == 0 {
+		i.EnvFrom = append(i.EnvFrom, source)
+		return
+	}
+
+	i.ContainerInjections = append(i.ContainerInjections, ContainerInjection{
+		Containers: containers,
+		EnvFrom:    []EnvFromSource{source},
+	})
+}
+
+// addVolume adds a volume to the PodSpec
+func (i *Injection) addVolume(volume v1.Volume) {
+	i.Volumes = append(i.Volumes, volume)
+}
+
+// addImagePullSecret adds an imagePullSecrets reference to the PodSpec
+func (i *Injection) addImagePullSecret(name string) {
+	i.ImagePullSecrets = append(i.ImagePullSecrets, v1.LocalObjectReference{Name: name})
+}
+
+// addVolumeMount adds a volumeMount for the selected containers, or for
+// every container matching the Injection selector when containers is empty
+func (i *Injection) addVolumeMount(containers ContainerSelector, mount v1.VolumeMount) {
+	if len(containers) == 0 {
+		i.VolumeMounts = append(i.VolumeMounts, mount)
+		return
+	}
+
+	i.ContainerInjections = append(i.ContainerInjections, ContainerInjection{
+		Containers:   containers,
+		VolumeMounts: []v1.VolumeMount{mount},
+	})
+}
+
+// injectPodSpec injects into the volumes and selected container lists of an
+// unstructured PodSpec
+func (i *Injection) injectPodSpec(podSpec map[string]interface{}) error {
+	for _, r := range i.Renames {
+		r.renameVolumeRefs(podSpec)
+		r.renameImagePullSecretRefs(podSpec)
+		for _, list := range containerLists {
+			containers, _ := podSpec[list].([]interface{})
+			for _, c := range containers {
+				if container, ok := c.(map[string]interface{}); ok {
+					r.renameContainerRefs(container)
+				}
+			}
+		}
+	}
+
+	if len(i.ImagePullSecrets) > 0 {
+		userSecrets, err := toUnstructuredList(i.ImagePullSecrets)
+		if err != nil {
+			return err
+		}
+
+		docSecrets, _ := podSpec["imagePullSecrets"].([]interface{})
+		podSpec["imagePullSecrets"] = mergeImagePullSecrets(docSecrets, userSecrets)
+	}
+
+	lists := i.ContainerLists
+	if len(lists) == 0 {
+		lists = containerLists[:1]
+	}
+
+	for _, list := range lists {
+		containers, _ := podSpec[list].([]interface{})
+		if err := i.injectContainers(containers); err != nil {
+			return err
+		}
+	}
+
+	// volumes no container of the PodSpec mounts are left out
+	mounted := mountedVolumeNames(podSpec)
+	volumes := []v1.Volume{}
+	for _, volume := range i.Volumes {
+		if mounted[volume.Name] {
+			volumes = append(volumes, volume)
+		}
+	}
+
+	if len(volumes) > 0 {
+		userVolumes, err := toUnstructuredList(volumes)
+		if err != nil {
+			return err
+		}
+
+		docVolumes, _ := podSpec["volumes"].([]interface{})
+		if podSpec["volumes"], err = mergeVolumes(docVolumes, userVolumes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mountedVolumeNames returns the names of the volumes mounted by the
+// containers of an unstructured PodSpec
+func mountedVolumeNames(podSpec map[string]interface{}) map[string]bool {
+	names := map[string]bool{}
+	for _, list := range containerLists {
+		containers, _ := podSpec[list].([]interface{})
+		for _, c := range containers {
+			container, _ := c.(map[string]interface{})
+			mounts, _ := container["volumeMounts"].([]interface{})
+			for _, m := range mounts {
+				names[fieldString(m, "name")] = true
+			}
+		}
+	}
+	return names
+}
+
+// annotatePodTemplate adds the Annotations to the metadata of an
+// unstructured pod template
+func (i *Injection) annotatePodTemplate(template map[string]interface{}) {
+	if len(i.Annotations) == 0 {
+		return
+	}
+
+	metadata, ok := template["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = map[string]interface{}{}
+		template["metadata"] = metadata
+	}
+
+	annotations, ok := metadata["annotations"].(map[string]interface{})
+	if !ok {
+		annotations = map[string]interface{}{}
+		metadata["annotations"] = annotations
+	}
+
+	for k, v := range i.Annotations {
+		annotations[k] = v
+	}
+}
+
+// injectContainers injects into each container of an unstructured
+// container list
+func (i *Injection) injectContainers(containers []interface{}) error {
+	for _, c := range containers {
+		container, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, r := range i.Renames {
+			r.renameContainerRefs(container)
+		}
+
+		name, _ := container["name"].(string)
+		userVars, userSources, userMounts, err := i.containerInjection(name)
+		if err != nil {
+			return err
+		}
+
+		injectContainerEnvVars(container, userVars)
+		injectContainerEnvFrom(container, userSources)
+		if err = injectContainerVolumeMounts(container, userMounts); err != nil {
+			return fmt.Errorf("container %s: %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+// containerInjection returns the unstructured EnvVars, EnvFrom entries and
+// VolumeMounts selected for a container
+func (i *Injection) containerInjection(name string) ([]interface{}, []interface{}, []interface{}, error) {
+	userVars := []interface{}{}
+	userSources := []interface{}{}
+	userMounts := []interface{}{}
+
+	injections := i.ContainerInjections
+	if i.Containers.Matches(name) {
+		injections = append([]ContainerInjection{{
+			EnvVars:      i.EnvVars,
+			EnvFrom:      i.EnvFrom,
+			VolumeMounts: i.VolumeMounts,
+		}}, injections...)
+	}
+
+	for _, c := range injections {
+		if !c.Containers.Matches(name) {
+			continue
+		}
+
+		envVars, err := toUnstructuredList(c.EnvVars)
+		if err != nil {
+			return userVars, userSources, userMounts, err
+		}
+		userVars = mergeEnvVars(userVars, envVars)
+
+		sources, err := toUnstructuredList(c.EnvFrom)
+		if err != nil {
+			return userVars, userSources, userMounts, err
+		}
+		userSources = mergeEnvFrom(userSources, sources)
+
+		mounts, err := toUnstructuredList(c.VolumeMounts)
+		if err != nil {
+			return userVars, userSources, userMounts, err
+		}
+		if userMounts, err = mergeVolumeMounts(userMounts, mounts); err != nil {
+			return userVars, userSources, userMounts, err
+		}
+	}
+
+	return userVars, userSources, userMounts, nil
+}
+
+// Matches checks whether a container name is selected
+func (s ContainerSelector) Matches(name string) bool {
+	if len(s) == 0 {
+		return true
+	}
+
+	for _, pattern := range s {
+		if isRegexPattern(pattern) {
+			if re, err := regexp.Compile(pattern[1 : len(pattern)-1]); err == nil && re.MatchString(name) {
+				return true
+			}
+		} else if ok, err := path.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate checks that every pattern of the ContainerSelector compiles
+func (s ContainerSelector) Validate() error {
+	for _, pattern := range s {
+		var err error
+		if isRegexPattern(pattern) {
+			_, err = regexp.Compile(pattern[1 : len(pattern)-1])
+		} else {
+			_, err = path.Match(pattern, "")
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s is not a valid container selector: %s", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// isRegexPattern checks whether a selector pattern is a /regex/
+func isRegexPattern(pattern string) bool {
+	return len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/")
+}
+
+// parseContainerLists parses a comma separated list of PodSpec container
+// lists, "all" selecting every one of them. An empty value selects only
+// "containers".
+func parseContainerLists(value string) ([]string, error) {
+	if value == "" {
+		return []string{"containers"}, nil
+	}
+
+	lists := []string{}
+	all := false
+	for _, list := range strings.Split(value, ",") {
+		if list == "all" {
+			all = true
+			continue
+		}
+		if !isContainerList(list) {
+			return lists, fmt.Errorf("%s is not a container list, must be one of %s or all", list, strings.Join(containerLists, ", "))
+		}
+		lists = append(lists, list)
+	}
+
+	if all {
+		return containerLists, nil
+	}
+	return lists, nil
+}
+
+// isContainerList checks whether a field is a PodSpec container list
+func isContainerList(field string) bool {
+	for _, list := range containerLists {
+		if field == list {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+package kenv
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/kubernetes/pkg/api/v1"
+)
+
+func TestInjectionContainerLists(t *testing.T) {
+	envVars := []v1.EnvVar{
+		v1.EnvVar{
+			Name:  "DB_HOST",
+			Value: "db",
+		},
+	}
+
+	tests := []struct {
+		lists []string
+		want  map[string]bool
+	}{
+		{
+			lists: []string{},
+			want:  map[string]bool{"app": true, "migrate": false, "debugger": false},
+		},
+		{
+			lists: []string{"initContainers"},
+			want:  map[string]bool{"app": false, "migrate": true, "debugger": false},
+		},
+		{
+			lists: []string{"containers", "initContainers"},
+			want:  map[string]bool{"app": true, "migrate": true, "debugger": false},
+		},
+		{
+			lists: containerLists,
+			want:  map[string]bool{"app": true, "migrate": true, "debugger": true},
+		},
+	}
+
+	for _, test := range tests {
+		doc := injectFixture(t, "fixtures/pod-multi-containers.yml", &Injection{
+			EnvVars:        envVars,
+			ContainerLists: test.lists,
+		})
+
+		for _, list := range containerLists {
+			for _, c := range findPath(doc, []string{"spec", list, "*"}) {
+				container := c.(map[string]interface{})
+				_, injected := container["env"]
+				if injected != test.want[container["name"].(string)] {
+					t.Fatalf("lists %v: container %s injected: %t", test.lists, container["name"], injected)
+				}
+			}
+		}
+	}
+}
+
+func TestParseContainerLists(t *testing.T) {
+	lists, err := parseContainerLists("containers,initContainers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(lists, []string{"containers", "initContainers"}) {
+		t.Fatalf("lists not equal: %v", lists)
+	}
+
+	// all is a superset of every other list
+	for _, value := range []string{"all", "containers,all", "all,initContainers"} {
+		lists, err = parseContainerLists(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(lists, containerLists) {
+			t.Fatalf("%s: lists not equal: %v", value, lists)
+		}
+	}
+
+	lists, err = parseContainerLists("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(lists, []string{"containers"}) {
+		t.Fatalf("lists not equal: %v", lists)
+	}
+
+	for _, value := range []string{"sidecars", "all,sidecars", "containers,"} {
+		if _, err = parseContainerLists(value); err == nil {
+			t.Fatalf("%s: expected error for unknown container list", value)
+		}
+	}
+}
+
+func TestContainerSelectorMatches(t *testing.T) {
+	tests := []struct {
+		selector ContainerSelector
+		name     string
+		want     bool
+	}{
+		{ContainerSelector{}, "nginx", true},
+		{ContainerSelector{"nginx"}, "nginx", true},
+		{ContainerSelector{"nginx"}, "log-shipper", false},
+		{ContainerSelector{"log-*"}, "log-shipper", true},
+		{ContainerSelector{"nginx", "mesh-*"}, "mesh-proxy", true},
+		{ContainerSelector{"/^(nginx|app)$/"}, "nginx", true},
+		{ContainerSelector{"/^(nginx|app)$/"}, "nginx-exporter", false},
+	}
+
+	for _, test := range tests {
+		if got := test.selector.Matches(test.name); got != test.want {
+			t.Fatalf("%v matching %s: want %t, got %t", test.selector, test.name, test.want, got)
+		}
+	}
+}
+
+func TestContainerSelectorValidate(t *testing.T) {
+	if err := (ContainerSelector{"nginx", "log-*", "/^app-[0-9]+$/"}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (ContainerSelector{"/(/"}).Validate(); err == nil {
+		t.Fatalf("expected error for invalid regex")
+	}
+
+	if err := (ContainerSelector{"[a-"}).Validate(); err == nil {
+		t.Fatalf("expected error for invalid glob")
+	}
+}
+
+func TestInjectionContainerEnvVars(t *testing.T) {
+	injection := &Injection{Containers: ContainerSelector{"nginx"}}
+	injection.addEnvVars(ContainerSelector{}, []v1.EnvVar{
+		v1.EnvVar{
+			Name:  "APP_SECRET",
+			Value: "secret",
+		},
+		v1.EnvVar{
+			Name:  "LOG_LEVEL",
+			Value: "info",
+		},
+	})
+	injection.addEnvVars(ContainerSelector{"log-*"}, []v1.EnvVar{
+		v1.EnvVar{
+			Name:  "LOG_LEVEL",
+			Value: "debug",
+		},
+	})
+	injection.addEnvVars(ContainerSelector{"nginx"}, []v1.EnvVar{
+		v1.EnvVar{
+			Name:  "LOG_LEVEL",
+			Value: "warn",
+		},
+	})
+
+	doc := injectFixture(t, "fixtures/deployment-sidecar.yml", injection)
+
+	resource := &podTemplateResource{}
+	if err := remarshal(doc, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]v1.EnvVar{
+		"nginx": []v1.EnvVar{
+			v1.EnvVar{
+				Name:  "LOG_LEVEL",
+				Value: "warn",
+			},
+			v1.EnvVar{
+				Name:  "APP_SECRET",
+				Value: "secret",
+			},
+		},
+		"log-shipper": []v1.EnvVar{
+			v1.EnvVar{
+				Name:  "LOG_LEVEL",
+				Value: "debug",
+			},
+		},
+		"mesh-proxy": nil,
+	}
+
+	for _, c := range resource.Spec.Template.Spec.Containers {
+		if !reflect.DeepEqual(c.Env, want[c.Name]) {
+			t.Fatalf("%s env vars not equal; want: %+v, got: %+v", c.Name, want[c.Name], c.Env)
+		}
+	}
+}
+
+func TestInjectionEnvFrom(t *testing.T) {
+	injection := &Injection{}
+	injection.addEnvFrom(ContainerSelector{}, EnvFromSource{
+		ConfigMapRef: &v1.LocalObjectReference{Name: "nginx"},
+	})
+	injection.addEnvFrom(ContainerSelector{}, EnvFromSource{
+		Prefix:    "APP_",
+		SecretRef: &v1.LocalObjectReference{Name: "nginx"},
+	})
+	injection.addEnvFrom(ContainerSelector{"nginx"}, EnvFromSource{
+		ConfigMapRef: &v1.LocalObjectReference{Name: "nginx"},
+	})
+
+	doc := injectFixture(t, "fixtures/deployment-envfrom.yml", injection)
+
+	envFrom := findPath(doc, []string{"spec", "template", "spec", "containers", "*", "envFrom"})[0]
+	got := []EnvFromSource{}
+	if err := remarshal(envFrom, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []EnvFromSource{
+		EnvFromSource{
+			ConfigMapRef: &v1.LocalObjectReference{Name: "shared"},
+		},
+		EnvFromSource{
+			Prefix:    "APP_",
+			SecretRef: &v1.LocalObjectReference{Name: "nginx"},
+		},
+		EnvFromSource{
+			ConfigMapRef: &v1.LocalObjectReference{Name: "nginx"},
+		},
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("envFrom not equal; want: %+v, got: %+v", want, got)
+	}
+
+	if _, ok := findPath(doc, []string{"spec", "template", "spec", "containers", "*"})[0].(map[string]interface{})["env"]; ok {
+		t.Fatalf("env should not be set in envFrom mode")
+	}
+}
@@ -0,0 +1,178 @@
+package kenv
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/kubernetes/pkg/api/unversioned"
+)
+
+// Injector applies an Injection to the PodSpec(s) of a resource doc and
+// returns the modified resource for printing
+type Injector interface {
+	Inject(k *KubeResource, injection *Injection) (interface{}, error)
+}
+
+// InjectorFunc allows an ordinary function to be used as an Injector
+type InjectorFunc func(k *KubeResource, injection *Injection) (interface{}, error)
+
+// Inject calls f(k, injection)
+func (f InjectorFunc) Inject(k *KubeResource, injection *Injection) (interface{}, error) {
+	return f(k, injection)
+}
+
+// injectors holds the registered Injectors keyed by group/version/kind
+var injectors = map[unversioned.GroupVersionKind]Injector{}
+
+// RegisterInjector registers an Injector for a group/version/kind. Leaving
+// the Version, or both the Group and Version, empty registers the Injector
+// for every matching apiVersion of the kind. A later registration for the
+// same key replaces the earlier one.
+func RegisterInjector(gvk unversioned.GroupVersionKind, injector Injector) {
+	injectors[gvk] = injector
+}
+
+// LookupInjector returns the Injector registered for a group/version/kind,
+// falling back to the version and then group agnostic registrations
+func LookupInjector(gvk unversioned.GroupVersionKind) (Injector, bool) {
+	return lookupInjector(injectors, gvk)
+}
+
+// lookupInjector returns the Injector of registry for a group/version/kind,
+// with the same fallbacks as LookupInjector
+func lookupInjector(registry map[unversioned.GroupVersionKind]Injector, gvk unversioned.GroupVersionKind) (Injector, bool) {
+	keys := []unversioned.GroupVersionKind{
+		gvk,
+		{Group: gvk.Group, Kind: gvk.Kind},
+		{Kind: gvk.Kind},
+	}
+
+	for _, key := range keys {
+		if injector, ok := registry[key]; ok {
+			return injector, true
+		}
+	}
+
+	return nil, false
+}
+
+// builtinKinds lists the apiVersions each built-in kind is served under
+var builtinKinds = map[string][]string{
+	"CronJob":               {"batch/v1", "batch/v1beta1", "batch/v2alpha1"},
+	"DaemonSet":             {"apps/v1", "apps/v1beta2", "extensions/v1beta1"},
+	"Deployment":            {"apps/v1", "apps/v1beta1", "apps/v1beta2", "extensions/v1beta1"},
+	"Job":                   {"batch/v1", "extensions/v1beta1"},
+	"Pod":                   {"v1"},
+	"ReplicaSet":            {"apps/v1", "apps/v1beta2", "extensions/v1beta1"},
+	"ReplicationController": {"v1"},
+	"ScheduledJob":          {"batch/v2alpha1"},
+	"StatefulSet":           {"apps/v1", "apps/v1beta1", "apps/v1beta2"},
+}
+
+// builtinPaths holds the PodSpec paths of the built-in kinds
+var builtinPaths = map[string][]string{
+	"CronJob":      {"spec.jobTemplate.spec.template.spec"},
+	"Pod":          {"spec"},
+	"ScheduledJob": {"spec.jobTemplate.spec.template.spec"},
+}
+
+// register the built-in kinds for each apiVersion they are served under
+func init() {
+	for kind, apiVersions := range builtinKinds {
+		paths, ok := builtinPaths[kind]
+		if !ok {
+			paths = []string{"spec.template.spec"}
+		}
+
+		for _, apiVersion := range apiVersions {
+			gvk := unversioned.FromAPIVersionAndKind(apiVersion, kind)
+			RegisterInjector(gvk, PathInjector{Paths: paths})
+		}
+	}
+}
+
+// PathInjector applies an Injection to the PodSpecs or container lists found at
+// dot separated field paths of an unstructured doc, e.g. "spec.template.spec"
+// or "spec.containers". A "*" path segment matches every element of a list
+// or every value of a map.
+type PathInjector struct {
+	Paths []string
+}
+
+// Inject applies the Injection at each of the PathInjector paths
+func (p PathInjector) Inject(k *KubeResource, injection *Injection) (interface{}, error) {
+	doc := map[string]interface{}{}
+	if err := unmarshalUnstructured(k.Data, &doc); err != nil {
+		return doc, err
+	}
+
+	found := false
+	for _, path := range p.Paths {
+		fields := strings.Split(path, ".")
+		for _, node := range findPath(doc, fields) {
+			var err error
+			switch n := node.(type) {
+			case map[string]interface{}:
+				err = injection.injectPodSpec(n)
+				found = true
+			case []interface{}:
+				if len(injection.Volumes) > 0 {
+					return doc, fmt.Errorf("%s has no PodSpec at %s to add volumes to", k.Kind, path)
+				}
+				err = injection.injectContainers(n)
+				found = true
+			}
+			if err != nil {
+				return doc, err
+			}
+		}
+	}
+
+	// e.g. a Deployment patch without a pod template
+	if !found {
+		Log.Warn("no PodSpec found, passing through", "kind", k.Kind, "paths", strings.Join(p.Paths, ", "))
+		return doc, nil
+	}
+
+	// annotate the pod templates holding a PodSpec
+	for _, path := range p.Paths {
+		fields := strings.Split(path, ".")
+		last := fields[len(fields)-1]
+		if last == "*" {
+			continue
+		}
+
+		for _, node := range findPath(doc, fields[:len(fields)-1]) {
+			template, ok := node.(map[string]interface{})
+			if _, isPodSpec := template[last].(map[string]interface{}); ok && isPodSpec {
+				injection.annotatePodTemplate(template)
+			}
+		}
+	}
+
+	return doc, nil
+}
+
+// parsePodPaths parses "[apiVersion/]Kind=path[,path]" flag values into
+// PathInjector paths keyed by group/version/kind
+func parsePodPaths(values []string) (map[unversioned.GroupVersionKind][]string, error) {
+	paths := map[unversioned.GroupVersionKind][]string{}
+
+	for _, value := range values {
+		split := strings.SplitN(value, "=", 2)
+		if len(split) < 2 || split[0] == "" || split[1] == "" {
+			return paths, fmt.Errorf("%s is not in [apiVersion/]Kind=path format", value)
+		}
+
+		gvk := unversioned.GroupVersionKind{Kind: split[0]}
+		if i := strings.LastIndex(split[0], "/"); i >= 0 {
+			gvk = unversioned.FromAPIVersionAndKind(split[0][:i], split[0][i+1:])
+		}
+
+		for _, path := range strings.Split(split[1], ",") {
+			paths[gvk] = append(paths[gvk], path)
+		}
+	}
+
+	return paths, nil
+}
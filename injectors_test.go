@@ -0,0 +1,238 @@
+package kenv
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/api/v1"
+)
+
+func TestLookupInjectorBuiltin(t *testing.T) {
+	for _, gvk := range []unversioned.GroupVersionKind{
+		unversioned.FromAPIVersionAndKind("apps/v1", "Deployment"),
+		unversioned.FromAPIVersionAndKind("extensions/v1beta1", "Deployment"),
+		unversioned.FromAPIVersionAndKind("apps/v1", "DaemonSet"),
+		unversioned.FromAPIVersionAndKind("apps/v1", "ReplicaSet"),
+		unversioned.FromAPIVersionAndKind("apps/v1", "StatefulSet"),
+		unversioned.FromAPIVersionAndKind("v1", "ReplicationController"),
+		unversioned.FromAPIVersionAndKind("v1", "Pod"),
+		unversioned.FromAPIVersionAndKind("batch/v1", "Job"),
+		unversioned.FromAPIVersionAndKind("batch/v1", "CronJob"),
+		unversioned.FromAPIVersionAndKind("batch/v1beta1", "CronJob"),
+	} {
+		if _, ok := LookupInjector(gvk); !ok {
+			t.Fatalf("no injector found for %s", gvk)
+		}
+	}
+
+	for _, gvk := range []unversioned.GroupVersionKind{
+		unversioned.FromAPIVersionAndKind("v1", "Service"),
+		unversioned.FromAPIVersionAndKind("example.com/v1", "Deployment"),
+		unversioned.FromAPIVersionAndKind("", "Deployment"),
+	} {
+		if _, ok := LookupInjector(gvk); ok {
+			t.Fatalf("unexpected injector for %s", gvk)
+		}
+	}
+}
+
+func TestRegisterInjector(t *testing.T) {
+	gvk := unversioned.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+	defer delete(injectors, gvk)
+
+	called := false
+	RegisterInjector(gvk, InjectorFunc(
+		func(k *KubeResource, injection *Injection) (interface{}, error) {
+			called = true
+			return k.UnmarshalGeneric()
+		},
+	))
+
+	injector, ok := LookupInjector(gvk)
+	if !ok {
+		t.Fatalf("registered injector not found")
+	}
+
+	k := &KubeResource{GroupVersionKind: gvk, Data: []byte(`{"kind": "Widget"}`)}
+	if _, err := injector.Inject(k, &Injection{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !called {
+		t.Fatalf("registered injector not called")
+	}
+
+	if _, ok := LookupInjector(unversioned.GroupVersionKind{Group: "example.com", Version: "v2", Kind: "Widget"}); ok {
+		t.Fatalf("injector should only match its own version")
+	}
+}
+
+func TestLookupInjectorPrefersExactMatch(t *testing.T) {
+	gvk := unversioned.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Deployment"}
+	defer delete(injectors, gvk)
+
+	exact := InjectorFunc(
+		func(k *KubeResource, injection *Injection) (interface{}, error) {
+			return "exact", nil
+		},
+	)
+	RegisterInjector(gvk, exact)
+
+	injector, _ := LookupInjector(gvk)
+	result, err := injector.Inject(&KubeResource{}, &Injection{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != "exact" {
+		t.Fatalf("expected exact match injector, got: %+v", result)
+	}
+}
+
+func TestPathInjector(t *testing.T) {
+	file, err := os.Open("fixtures/rollout.yml")
+	defer file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resources, err := ParseDocs(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envVars := []v1.EnvVar{
+		v1.EnvVar{
+			Name:  "key1",
+			Value: "value1",
+		},
+	}
+
+	result, err := PathInjector{Paths: []string{"spec.template.spec"}}.Inject(&resources[0], &Injection{EnvVars: envVars})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rollout := &v1.ReplicationController{}
+	if err = remarshal(result, rollout); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []v1.EnvVar{
+		v1.EnvVar{
+			Name:  "key1",
+			Value: "value1",
+		},
+		v1.EnvVar{
+			Name:  "existing",
+			Value: "kept",
+		},
+	}
+
+	for _, c := range rollout.Spec.Template.Spec.Containers {
+		if !reflect.DeepEqual(c.Env, want) {
+			t.Fatalf("container env vars not equal; want: %+v, got: %+v", want, c.Env)
+		}
+	}
+
+	spec := result.(map[string]interface{})["spec"].(map[string]interface{})
+	if spec["strategy"] == nil {
+		t.Fatalf("unknown fields not preserved: %+v", spec)
+	}
+}
+
+func TestPathInjectorWildcardContainerList(t *testing.T) {
+	k := &KubeResource{
+		GroupVersionKind: unversioned.GroupVersionKind{Kind: "Workflow"},
+		Data: []byte(`{"kind": "Workflow", "spec": {"steps": [
+			{"containers": [{"name": "a"}]},
+			{"containers": [{"name": "b"}]}
+		]}}`),
+	}
+
+	envVars := []v1.EnvVar{
+		v1.EnvVar{
+			Name:  "key1",
+			Value: "value1",
+		},
+	}
+
+	result, err := PathInjector{Paths: []string{"spec.steps.*.containers"}}.Inject(k, &Injection{EnvVars: envVars})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workflow := struct {
+		Spec struct {
+			Steps []v1.PodSpec `json:"steps"`
+		} `json:"spec"`
+	}{}
+	if err = remarshal(result, &workflow); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(workflow.Spec.Steps) != 2 {
+		t.Fatalf("steps not preserved: %+v", workflow)
+	}
+
+	for _, step := range workflow.Spec.Steps {
+		if !reflect.DeepEqual(step.Containers[0].Env, envVars) {
+			t.Fatalf("container env vars not equal")
+		}
+	}
+}
+
+func TestPathInjectorMissingPath(t *testing.T) {
+	k := &KubeResource{
+		GroupVersionKind: unversioned.GroupVersionKind{Kind: "Deployment"},
+		Data:             []byte(`{"kind": "Deployment", "spec": {"replicas": 2}}`),
+	}
+
+	injection := &Injection{
+		EnvVars: []v1.EnvVar{v1.EnvVar{Name: "key", Value: "value"}},
+	}
+
+	doc, err := (PathInjector{Paths: []string{"spec.template.spec"}}).Inject(k, injection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := k.UnmarshalGeneric()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(want, doc) {
+		t.Fatalf("doc without PodSpec not passed through; want: %+v, got: %+v", want, doc)
+	}
+}
+
+func TestParsePodPaths(t *testing.T) {
+	paths, err := parsePodPaths([]string{
+		"Rollout=spec.template.spec",
+		"serving.knative.dev/v1/Service=spec.template.spec,spec.containers",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[unversioned.GroupVersionKind][]string{
+		unversioned.GroupVersionKind{Kind: "Rollout"}: []string{
+			"spec.template.spec",
+		},
+		unversioned.GroupVersionKind{Group: "serving.knative.dev", Version: "v1", Kind: "Service"}: []string{
+			"spec.template.spec",
+			"spec.containers",
+		},
+	}
+
+	if !reflect.DeepEqual(want, paths) {
+		t.Fatalf("paths not equal; want: %+v, got: %+v", want, paths)
+	}
+
+	if _, err := parsePodPaths([]string{"Rollout"}); err == nil {
+		t.Fatalf("expected error for missing path")
+	}
+}
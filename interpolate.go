@@ -0,0 +1,184 @@
+package kenv
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// interpolator resolves ${KEY} and ${KEY:-default} references between Vars
+type interpolator struct {
+	vars     Vars
+	index    map[string]int
+	resolved map[int]string
+	chain    []string
+	env      bool
+}
+
+// interpolateVars expands ${KEY} and ${KEY:-default} references in the
+// values of vars, except Literal ones. References resolve to the last
+// definition of KEY in vars, then to the process environment when
+// opts.EnvFallback is set. The default is used when KEY is unset or empty,
+// and $$ is a literal $.
+func interpolateVars(vars Vars, opts VarsOptions) (Vars, error) {
+	in := &interpolator{
+		vars:     vars,
+		index:    map[string]int{},
+		resolved: map[int]string{},
+		env:      opts.EnvFallback,
+	}
+	for i, v := range vars {
+		in.index[v.Key] = i
+	}
+
+	result := make(Vars, len(vars))
+	for i, v := range vars {
+		if v.Literal {
+			result[i] = v
+			continue
+		}
+
+		value, err := in.resolve(i)
+		if err != nil {
+			return vars, err
+		}
+		result[i] = v
+		result[i].Value = value
+	}
+
+	return result, nil
+}
+
+// resolve returns the expanded value of the var at index i
+func (in *interpolator) resolve(i int) (string, error) {
+	if value, ok := in.resolved[i]; ok {
+		return value, nil
+	}
+	if in.vars[i].Literal {
+		return in.vars[i].Value, nil
+	}
+
+	key := in.vars[i].Key
+	for n, k := range in.chain {
+		if k == key {
+			chain := append(append([]string{}, in.chain[n:]...), key)
+			return "", fmt.Errorf("interpolation cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+
+	in.chain = append(in.chain, key)
+	value, err := in.expand(in.vars[i].Value)
+	in.chain = in.chain[:len(in.chain)-1]
+	if err != nil {
+		return "", err
+	}
+
+	in.resolved[i] = value
+	return value, nil
+}
+
+// lookup returns the value of key and whether it is set
+func (in *interpolator) lookup(key string) (string, bool, error) {
+	if i, ok := in.index[key]; ok {
+		value, err := in.resolve(i)
+		return value, true, err
+	}
+
+	if in.env {
+		value, ok := os.LookupEnv(key)
+		return value, ok, nil
+	}
+
+	return "", false, nil
+}
+
+// expand replaces the references in value
+func (in *interpolator) expand(value string) (string, error) {
+	if !strings.Contains(value, "$") {
+		return value, nil
+	}
+
+	out := []byte{}
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' || i+1 == len(value) {
+			out = append(out, value[i])
+			continue
+		}
+
+		switch value[i+1] {
+		case '$':
+			out = append(out, '$')
+			i++
+			continue
+		case '{':
+		default:
+			out = append(out, value[i])
+			continue
+		}
+
+		end := matchingBrace(value, i+2)
+		if end < 0 {
+			return "", in.errorf("unterminated reference in %q", value)
+		}
+
+		expanded, err := in.reference(value[i+2 : end])
+		if err != nil {
+			return "", err
+		}
+
+		out = append(out, expanded...)
+		i = end
+	}
+
+	return string(out), nil
+}
+
+// reference expands the body of a ${...} reference
+func (in *interpolator) reference(body string) (string, error) {
+	key, def, hasDefault := body, "", false
+	if n := strings.Index(body, ":-"); n >= 0 {
+		key, def, hasDefault = body[:n], body[n+2:], true
+	}
+
+	if key == "" {
+		return "", in.errorf("empty reference ${%s}", body)
+	}
+
+	value, ok, err := in.lookup(key)
+	if err != nil {
+		return "", err
+	}
+
+	if hasDefault && value == "" {
+		return in.expand(def)
+	}
+	if !ok {
+		return "", in.errorf("undefined variable %s", key)
+	}
+
+	return value, nil
+}
+
+// errorf returns an error naming the var being resolved
+func (in *interpolator) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %s", in.chain[len(in.chain)-1], fmt.Sprintf(format, args...))
+}
+
+// matchingBrace returns the index of the } closing a reference whose body
+// starts at start, allowing nested references in defaults, or -1
+func matchingBrace(value string, start int) int {
+	depth := 0
+	for i := start; i < len(value); i++ {
+		switch {
+		case value[i] == '$' && i+1 < len(value) && value[i+1] == '{':
+			depth++
+			i++
+		case value[i] == '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
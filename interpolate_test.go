@@ -0,0 +1,147 @@
+package kenv
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInterpolateVars(t *testing.T) {
+	vars := Vars{
+		Var{Key: "DB_URL", Value: "postgres://${DB_HOST}:${DB_PORT:-5432}/app"},
+		Var{Key: "DB_HOST", Value: "${REGION}.db.example.com"},
+		Var{Key: "REGION", Value: "us-east-1"},
+		Var{Key: "PRICE", Value: "$$5 or $5"},
+		Var{Key: "CACHE", Value: "${CACHE_HOST:-${DB_HOST}}"},
+	}
+
+	want := Vars{
+		Var{Key: "DB_URL", Value: "postgres://us-east-1.db.example.com:5432/app"},
+		Var{Key: "DB_HOST", Value: "us-east-1.db.example.com"},
+		Var{Key: "REGION", Value: "us-east-1"},
+		Var{Key: "PRICE", Value: "$5 or $5"},
+		Var{Key: "CACHE", Value: "us-east-1.db.example.com"},
+	}
+
+	got, err := interpolateVars(vars, VarsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("not equal, wanted: %+v, got: %+v", want, got)
+	}
+}
+
+func TestInterpolateVarsLastDefinitionWins(t *testing.T) {
+	vars := Vars{
+		Var{Key: "HOST", Value: "a"},
+		Var{Key: "URL", Value: "http://${HOST}"},
+		Var{Key: "HOST", Value: "b"},
+	}
+
+	got, err := interpolateVars(vars, VarsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got[1].Value != "http://b" {
+		t.Fatalf("expected http://b, got %s", got[1].Value)
+	}
+}
+
+func TestInterpolateVarsErrors(t *testing.T) {
+	tests := []struct {
+		vars Vars
+		want string
+	}{
+		{
+			Vars{
+				Var{Key: "A", Value: "${B}"},
+				Var{Key: "B", Value: "${C}"},
+				Var{Key: "C", Value: "${A}"},
+			},
+			"interpolation cycle: A -> B -> C -> A",
+		},
+		{
+			Vars{Var{Key: "A", Value: "${A}"}},
+			"interpolation cycle: A -> A",
+		},
+		{
+			Vars{Var{Key: "A", Value: "${MISSING}"}},
+			"A: undefined variable MISSING",
+		},
+		{
+			Vars{Var{Key: "A", Value: "${B"}},
+			"A: unterminated reference",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := interpolateVars(test.vars, VarsOptions{})
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Fatalf("expected error %q, got %v", test.want, err)
+		}
+	}
+}
+
+func TestInterpolateVarsEnvFallback(t *testing.T) {
+	os.Setenv("KENV_TEST_HOST", "env.example.com")
+	defer os.Unsetenv("KENV_TEST_HOST")
+
+	vars := Vars{Var{Key: "URL", Value: "http://${KENV_TEST_HOST}"}}
+
+	if _, err := interpolateVars(vars, VarsOptions{}); err == nil {
+		t.Fatal("expected error without env fallback")
+	}
+
+	got, err := interpolateVars(vars, VarsOptions{EnvFallback: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got[0].Value != "http://env.example.com" {
+		t.Fatalf("expected http://env.example.com, got %s", got[0].Value)
+	}
+}
+
+func TestNewTargetedVarsFromFilesInterpolateOptIn(t *testing.T) {
+	want := Vars{
+		Var{Key: "A", Value: "x$$y"},
+		Var{Key: "B", Value: "lit${Z}", Literal: true},
+		Var{Key: "C", Value: "${A}"},
+		Var{Key: "D", Value: "${UNKNOWN}"},
+	}
+
+	// values are used verbatim unless interpolation is enabled
+	groups, err := newTargetedVarsFromFiles([]string{"fixtures/dollar.env"}, VarsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars := joinTargetedVars(groups)
+	if !reflect.DeepEqual(want, vars) {
+		t.Fatalf("not equal, wanted: %+v, got: %+v", want, vars)
+	}
+
+	_, err = newTargetedVarsFromFiles([]string{"fixtures/dollar.env"}, VarsOptions{Interpolate: true})
+	if err == nil || !strings.Contains(err.Error(), "undefined variable UNKNOWN") {
+		t.Fatalf("expected undefined variable error, got %v", err)
+	}
+
+	want = Vars{
+		Var{Key: "A", Value: "x$y"},
+		Var{Key: "B", Value: "lit${Z}", Literal: true},
+		Var{Key: "C", Value: "x$y"},
+	}
+
+	got, err := interpolateVars(vars[:3], VarsOptions{Interpolate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("not equal, wanted: %+v, got: %+v", want, got)
+	}
+}
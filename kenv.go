@@ -0,0 +1,405 @@
+// Package kenv injects variables read from var files and sources into
+// Kubernetes resource documents, as plaintext environment variables or as
+// references to a generated ConfigMap or Secret.
+//
+// Programs embedding kenv can inject into their own kinds with
+// RegisterInjector, and read their own var sources and formats with
+// RegisterVarSource and RegisterVarFormat. The kenv command in cmd/kenv is a
+// thin CLI over Run.
+package kenv
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/api/v1"
+)
+
+// Config holds the options of a run, mirroring the kenv command line flags
+type Config struct {
+	// Name and Namespace of the generated ConfigMap and Secret
+	Name      string
+	Namespace string
+	// ConvertKeys converts ConfigMap keys to support k8s version < 1.4
+	ConvertKeys bool
+
+	// VarsFiles, SecretFiles and ConfigMapFiles hold the files or source
+	// URIs injected as plaintext, Secret and ConfigMap vars, optionally as
+	// container=file
+	VarsFiles      []string
+	SecretFiles    []string
+	ConfigMapFiles []string
+	// Vars configures how var files are read
+	Vars VarsOptions
+
+	// PodPaths registers injectors for custom kinds, as Kind=path
+	PodPaths []string
+	// ContainerLists holds the comma separated PodSpec container lists to
+	// inject into
+	ContainerLists string
+	// Containers only injects into containers matching a name, glob or
+	// /regex/
+	Containers []string
+
+	// SelectNames, SelectKinds and SelectLabels only inject into matching
+	// resources
+	SelectNames  []string
+	SelectKinds  []string
+	SelectLabels string
+
+	// EnvFrom injects the ConfigMap and Secret as envFrom references,
+	// prefixed with EnvFromPrefix
+	EnvFrom       bool
+	EnvFromPrefix string
+	// ConfigMapMount and SecretMount mount the ConfigMap and Secret as files
+	// at a path instead
+	ConfigMapMount string
+	SecretMount    string
+	// MountItems only mounts some keys, as key=path
+	MountItems []string
+	// MountMode holds the octal default file mode of mounted keys
+	MountMode string
+
+	// HashSuffix appends a hash of the data to the ConfigMap and Secret names
+	HashSuffix bool
+	// ConfigHash annotates pod templates with a hash of all injected vars
+	ConfigHash bool
+
+	// SecretType holds the type of the Secret, e.g. tls
+	SecretType string
+	// SealedSecretsCert seals the Secret with a sealed-secrets certificate,
+	// in SealingScope
+	SealedSecretsCert string
+	SealingScope      string
+
+	// YAML writes the output as YAML instead of JSON
+	YAML bool
+}
+
+// Run injects the vars of config into the resource docs read from in, and
+// writes the generated Secret and ConfigMap followed by the docs to out
+func Run(config Config, in io.Reader, out io.Writer) error {
+	paths, err := parsePodPaths(config.PodPaths)
+	if err != nil {
+		return err
+	}
+
+	// -pod-path injectors only apply to this run, ahead of registered ones
+	podPathInjectors := map[unversioned.GroupVersionKind]Injector{}
+	for gvk, p := range paths {
+		podPathInjectors[gvk] = PathInjector{Paths: p}
+	}
+
+	resources, err := ParseDocs(in)
+	if err != nil {
+		return err
+	}
+
+	selector, err := newResourceSelector(config.SelectNames, config.SelectKinds, config.SelectLabels)
+	if err != nil {
+		return err
+	}
+
+	lists, err := parseContainerLists(config.ContainerLists)
+	if err != nil {
+		return err
+	}
+
+	items, err := parseMountItems(config.MountItems)
+	if err != nil {
+		return err
+	}
+
+	mode, err := parseFileMode(config.MountMode)
+	if err != nil {
+		return err
+	}
+
+	injection := &Injection{
+		Containers:     ContainerSelector(config.Containers),
+		ContainerLists: lists,
+	}
+
+	if err = injection.Containers.Validate(); err != nil {
+		return err
+	}
+
+	// injected vars by source, for the config hash annotation
+	injectedVars := map[string][]TargetedVars{}
+
+	// volumes mounting the ConfigMap and Secret, for checking -mount-item
+	mountedVolumes := []v1.Volume{}
+
+	if len(config.VarsFiles) > 0 {
+		groups, err := newTargetedVarsFromFiles(config.VarsFiles, config.Vars)
+		if err != nil {
+			return err
+		}
+
+		for _, g := range groups {
+			e, err := g.Vars.toEnvVar()
+			if err != nil {
+				return err
+			}
+
+			injection.addEnvVars(g.Containers, e)
+		}
+
+		injectedVars["plaintext"] = groups
+	}
+
+	if len(config.SecretFiles) > 0 {
+		if config.Name == "" {
+			return errors.New("A name must be set for the Secret resource")
+		}
+
+		// the suffix is a hash of the Secret data, which sealing protects
+		if config.HashSuffix && config.SealedSecretsCert != "" {
+			return errors.New("-hash-suffix cannot be used with -sealed-secrets-cert, as the hash reveals the plaintext Secret data")
+		}
+
+		groups, err := newTargetedVarsFromFiles(config.SecretFiles, config.Vars)
+		if err != nil {
+			return err
+		}
+
+		secretType, err := parseSecretType(config.SecretType)
+		if err != nil {
+			return err
+		}
+
+		// TLS certificates and keys are files, so they are always mounted
+		mountPath := config.SecretMount
+		if secretType == v1.SecretTypeTLS && mountPath == "" {
+			mountPath = defaultTLSMountPath
+		}
+
+		if secretType != secretTypeDockerConfigJSON {
+			flag := "-env-from"
+			if mountPath != "" {
+				flag = "-secret-mount"
+			}
+			if mountPath != "" || config.EnvFrom {
+				if err = requireUntargeted(groups, flag); err != nil {
+					return err
+				}
+			}
+		}
+
+		injectedVars["secret"] = groups
+		secretVars, err := typedSecretVars(secretType, joinTargetedVars(groups))
+		if err != nil {
+			return err
+		}
+
+		_, secret, err := secretVars.toSecret(config.Name, config.Namespace, config.ConvertKeys)
+		if err != nil {
+			return err
+		}
+
+		// Opaque is the default, leave it out to keep the output unchanged
+		if secretType != v1.SecretTypeOpaque {
+			secret.Type = secretType
+		}
+
+		secretName := config.Name
+		if config.HashSuffix {
+			if secretName, err = hashedName(config.Name, secret); err != nil {
+				return err
+			}
+
+			secret.Name = secretName
+			injection.Renames = append(injection.Renames, ResourceRename{
+				Kind:    "Secret",
+				Name:    config.Name,
+				NewName: secretName,
+			})
+		}
+
+		var secretResource interface{} = secret
+		if config.SealedSecretsCert != "" {
+			scope, err := parseSealingScope(config.SealingScope)
+			if err != nil {
+				return err
+			}
+
+			key, err := readSealingKey(config.SealedSecretsCert)
+			if err != nil {
+				return err
+			}
+
+			if secretResource, err = sealSecret(secret, key, scope, rand.Reader); err != nil {
+				return err
+			}
+		}
+
+		if err = printResource(out, secretResource, config.YAML); err != nil {
+			return err
+		}
+
+		// registry credentials are only used by the kubelet
+		if secretType == secretTypeDockerConfigJSON {
+			injection.addImagePullSecret(secretName)
+			groups = nil
+		}
+
+		if mountPath != "" && len(groups) > 0 {
+			volume, err := secretVolume(config.Name, secret, items, mode)
+			if err != nil {
+				return err
+			}
+
+			injection.addVolume(volume)
+			injection.addVolumeMount(groups[0].Containers, v1.VolumeMount{
+				Name:      volume.Name,
+				MountPath: mountPath,
+				ReadOnly:  true,
+			})
+			mountedVolumes = append(mountedVolumes, volume)
+			groups = nil
+		}
+
+		for _, g := range groups {
+			if config.EnvFrom {
+				injection.addEnvFrom(g.Containers, EnvFromSource{
+					Prefix:    config.EnvFromPrefix,
+					SecretRef: &v1.LocalObjectReference{Name: secretName},
+				})
+				continue
+			}
+
+			e, _, err := g.Vars.toSecret(secretName, config.Namespace, config.ConvertKeys)
+			if err != nil {
+				return err
+			}
+
+			injection.addEnvVars(g.Containers, e)
+		}
+	}
+
+	if len(config.ConfigMapFiles) > 0 {
+		if config.Name == "" {
+			return errors.New("A name must be set for the ConfigMap resource")
+		}
+
+		groups, err := newTargetedVarsFromFiles(config.ConfigMapFiles, config.Vars)
+		if err != nil {
+			return err
+		}
+
+		if config.ConfigMapMount != "" {
+			if err = requireUntargeted(groups, "-configmap-mount"); err != nil {
+				return err
+			}
+		} else if config.EnvFrom {
+			if err = requireUntargeted(groups, "-env-from"); err != nil {
+				return err
+			}
+		}
+
+		injectedVars["configmap"] = groups
+		_, configMap, err := joinTargetedVars(groups).toConfigMap(config.Name, config.Namespace, config.ConvertKeys)
+		if err != nil {
+			return err
+		}
+
+		configMapName := config.Name
+		if config.HashSuffix {
+			if configMapName, err = hashedName(config.Name, configMap); err != nil {
+				return err
+			}
+
+			configMap.Name = configMapName
+			injection.Renames = append(injection.Renames, ResourceRename{
+				Kind:    "ConfigMap",
+				Name:    config.Name,
+				NewName: configMapName,
+			})
+		}
+
+		if err = printResource(out, configMap, config.YAML); err != nil {
+			return err
+		}
+
+		if config.ConfigMapMount != "" {
+			volume, err := configMapVolume(config.Name, configMap, items, mode)
+			if err != nil {
+				return err
+			}
+
+			injection.addVolume(volume)
+			injection.addVolumeMount(groups[0].Containers, v1.VolumeMount{
+				Name:      volume.Name,
+				MountPath: config.ConfigMapMount,
+				ReadOnly:  true,
+			})
+			mountedVolumes = append(mountedVolumes, volume)
+			groups = nil
+		}
+
+		for _, g := range groups {
+			if config.EnvFrom {
+				injection.addEnvFrom(g.Containers, EnvFromSource{
+					Prefix:       config.EnvFromPrefix,
+					ConfigMapRef: &v1.LocalObjectReference{Name: configMapName},
+				})
+				continue
+			}
+
+			e, _, err := g.Vars.toConfigMap(configMapName, config.Namespace, config.ConvertKeys)
+			if err != nil {
+				return err
+			}
+
+			injection.addEnvVars(g.Containers, e)
+		}
+	}
+
+	if err = checkMountItems(items, mountedVolumes); err != nil {
+		return err
+	}
+
+	if config.ConfigHash {
+		hash, err := configHash(injectedVars)
+		if err != nil {
+			return err
+		}
+
+		injection.Annotations = map[string]string{configHashAnnotation: hash}
+	}
+
+	// inject environment variables into the selected resource docs
+	// and write the result to out
+	for _, resource := range resources {
+		// selectors are only evaluated for documents kenv injects into
+		injector, selected := lookupInjector(podPathInjectors, resource.GroupVersionKind)
+		if !selected {
+			injector, selected = LookupInjector(resource.GroupVersionKind)
+		}
+		if selected {
+			if selected, err = selector.Matches(&resource); err != nil {
+				return err
+			}
+		}
+
+		var result interface{}
+		if selected {
+			Log.Debug("injecting", "kind", resource.Kind)
+			result, err = injector.Inject(&resource, injection)
+		} else {
+			Log.Debug("passing through", "kind", resource.Kind)
+			result, err = resource.UnmarshalGeneric()
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = printResource(out, result, config.YAML); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
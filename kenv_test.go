@@ -0,0 +1,160 @@
+package kenv
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/api/v1"
+)
+
+func TestRun(t *testing.T) {
+	envVars := []v1.EnvVar{
+		v1.EnvVar{Name: "KVKey1", Value: "KVValue1"},
+		v1.EnvVar{Name: "kvkey2", Value: "kvvalue2"},
+		v1.EnvVar{Name: "key1", Value: "base"},
+		v1.EnvVar{Name: "existing", Value: "kept"},
+	}
+
+	// a program embedding kenv registering its own kind
+	gvk := unversioned.GroupVersionKind{Group: "argoproj.io", Kind: "Rollout"}
+	defer delete(injectors, gvk)
+
+	called := false
+	RegisterInjector(gvk, InjectorFunc(
+		func(k *KubeResource, injection *Injection) (interface{}, error) {
+			called = true
+			return PathInjector{Paths: []string{"spec.template.spec"}}.Inject(k, injection)
+		},
+	))
+
+	in, err := os.Open("fixtures/rollout.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	out := &bytes.Buffer{}
+	config := Config{
+		VarsFiles: []string{"fixtures/vars.env"},
+	}
+	if err := Run(config, in, out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !called {
+		t.Fatalf("registered injector not called")
+	}
+
+	resource := &podTemplateResource{}
+	decoder := json.NewDecoder(out)
+	if err := decoder.Decode(resource); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := resource.Spec.Template.Spec.Containers[0].Env; !reflect.DeepEqual(envVars, got) {
+		t.Fatalf("container env vars not equal; want: %+v, got: %+v", envVars, got)
+	}
+
+	if err := decoder.Decode(resource); err != io.EOF {
+		t.Fatalf("expected a single doc, got %v", err)
+	}
+}
+
+func TestRunPodPaths(t *testing.T) {
+	in, err := os.Open("fixtures/rollout.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	out := &bytes.Buffer{}
+	config := Config{
+		VarsFiles: []string{"fixtures/vars.env"},
+		PodPaths:  []string{"argoproj.io/v1alpha1/Rollout=spec.template.spec"},
+	}
+	if err := Run(config, in, out); err != nil {
+		t.Fatal(err)
+	}
+
+	resource := &podTemplateResource{}
+	if err := json.NewDecoder(out).Decode(resource); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := resource.Spec.Template.Spec.Containers[0].Env; len(got) != 4 {
+		t.Fatalf("expected 4 container env vars, got: %+v", got)
+	}
+
+	// -pod-path injectors only apply to the run
+	gvk := unversioned.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}
+	if _, ok := LookupInjector(gvk); ok {
+		t.Fatalf("-pod-path injector registered after run")
+	}
+}
+
+func TestRunVarSource(t *testing.T) {
+	// a program embedding kenv registering its own source
+	defer delete(varSources, "static")
+	RegisterVarSource("static", VarSourceFunc(
+		func(uri string, opts VarsOptions) (Vars, error) {
+			return Vars{Var{Key: "source", Value: trimScheme(uri)}}, nil
+		},
+	))
+
+	in, err := os.Open("fixtures/deployment.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	out := &bytes.Buffer{}
+	config := Config{
+		VarsFiles: []string{"static://app"},
+	}
+	if err := Run(config, in, out); err != nil {
+		t.Fatal(err)
+	}
+
+	resource := &podTemplateResource{}
+	if err := json.NewDecoder(out).Decode(resource); err != nil {
+		t.Fatal(err)
+	}
+
+	envVars := []v1.EnvVar{v1.EnvVar{Name: "source", Value: "app"}}
+	if got := resource.Spec.Template.Spec.Containers[0].Env; !reflect.DeepEqual(envVars, got) {
+		t.Fatalf("container env vars not equal; want: %+v, got: %+v", envVars, got)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []Config{
+		Config{SecretFiles: []string{"fixtures/secrets.yml"}},
+		Config{ConfigMapFiles: []string{"fixtures/configmap.env"}},
+		Config{PodPaths: []string{"Rollout"}},
+		Config{
+			Name:              "nginx",
+			SecretFiles:       []string{"fixtures/secrets.yml"},
+			HashSuffix:        true,
+			SealedSecretsCert: "fixtures/sealed-secrets.pem",
+		},
+		Config{VarsFiles: []string{"fixtures/missing.env"}},
+	}
+
+	for _, config := range tests {
+		in, err := os.Open("fixtures/deployment.yml")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = Run(config, in, &bytes.Buffer{})
+		in.Close()
+		if err == nil {
+			t.Fatalf("expected error running %+v", config)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package kenv
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LogLevel is the severity of a log message
+type LogLevel int
+
+// log levels in increasing severity
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// logLevelNames maps log levels to their names
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+// String returns the name of the LogLevel
+func (l LogLevel) String() string {
+	if l < LevelDebug || l > LevelError {
+		return strconv.Itoa(int(l))
+	}
+	return logLevelNames[l]
+}
+
+// ParseLogLevel parses a log level name
+func ParseLogLevel(name string) (LogLevel, error) {
+	for i, n := range logLevelNames {
+		if strings.EqualFold(name, n) {
+			return LogLevel(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("%s is not a log level, must be one of %s", name, strings.Join(logLevelNames, ", "))
+}
+
+// Logger writes leveled diagnostics in logfmt, e.g.
+// level=warn msg="skipping line" file=vars.env line=3
+type Logger struct {
+	Out   io.Writer
+	Level LogLevel
+}
+
+// Log writes diagnostics to STDERR, keeping STDOUT for resource docs
+var Log = &Logger{Out: os.Stderr, Level: LevelInfo}
+
+// Debug logs a message with key/value fields at debug level
+func (l *Logger) Debug(msg string, fields ...interface{}) {
+	l.log(LevelDebug, msg, fields...)
+}
+
+// Info logs a message with key/value fields at info level
+func (l *Logger) Info(msg string, fields ...interface{}) {
+	l.log(LevelInfo, msg, fields...)
+}
+
+// Warn logs a message with key/value fields at warn level
+func (l *Logger) Warn(msg string, fields ...interface{}) {
+	l.log(LevelWarn, msg, fields...)
+}
+
+// Error logs a message with key/value fields at error level
+func (l *Logger) Error(msg string, fields ...interface{}) {
+	l.log(LevelError, msg, fields...)
+}
+
+// Fatal logs an error and exits
+func (l *Logger) Fatal(v interface{}, fields ...interface{}) {
+	l.log(LevelError, fmt.Sprint(v), fields...)
+	os.Exit(1)
+}
+
+// log writes a message if its level is enabled
+func (l *Logger) log(level LogLevel, msg string, fields ...interface{}) {
+	if level < l.Level {
+		return
+	}
+
+	line := []string{
+		"level=" + level.String(),
+		"msg=" + logfmtValue(msg),
+	}
+
+	for i := 0; i < len(fields); i += 2 {
+		value := interface{}("")
+		if i+1 < len(fields) {
+			value = fields[i+1]
+		}
+		line = append(line, fmt.Sprintf("%v=%s", fields[i], logfmtValue(fmt.Sprint(value))))
+	}
+
+	fmt.Fprintln(l.Out, strings.Join(line, " "))
+}
+
+// logfmtValue quotes a value when it contains spaces, quotes or equal signs
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,44 @@
+package kenv
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLoggerLevels(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := &Logger{Out: buf, Level: LevelWarn}
+
+	l.Debug("debug")
+	l.Info("info")
+	l.Warn("warn")
+	l.Error("error")
+
+	want := "level=warn msg=warn\nlevel=error msg=error\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestLoggerFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := &Logger{Out: buf, Level: LevelDebug}
+
+	l.Info("read vars", "file", "my vars.env", "count", 3, "empty", "")
+
+	want := `level=info msg="read vars" file="my vars.env" count=3 empty=""` + "\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	level, err := ParseLogLevel("DEBUG")
+	if err != nil || level != LevelDebug {
+		t.Fatalf("expected debug, got %v, %v", level, err)
+	}
+
+	if _, err := ParseLogLevel("verbose"); err == nil {
+		t.Fatal("expected error for unknown level")
+	}
+}
@@ -1,20 +1,22 @@
-package main
+package kenv
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
+	"reflect"
+	"sort"
 
 	"k8s.io/kubernetes/pkg/api/unversioned"
-	"k8s.io/kubernetes/pkg/api/v1"
-	"k8s.io/kubernetes/pkg/apis/extensions/v1beta1"
 	"k8s.io/kubernetes/pkg/runtime"
 	"k8s.io/kubernetes/pkg/util/yaml"
 )
 
-// KubeResource represents a resource kind and raw data to be used
-// later for injecting EnvVars
+// KubeResource represents a resource group/version/kind and raw data to
+// be used later for injecting EnvVars
 type KubeResource struct {
-	Kind string
+	unversioned.GroupVersionKind
 	Data []byte
 }
 
@@ -33,107 +35,238 @@ func ParseDocs(reader io.Reader) ([]KubeResource, error) {
 			return resources, err
 		}
 
-		kind, err := getResourceKind(rawExtension.Raw)
+		gvk, err := getResourceGVK(rawExtension.Raw)
 		if err != nil {
 			return resources, err
 		}
 
 		resources = append(resources, KubeResource{
-			Kind: kind,
-			Data: rawExtension.Raw,
+			GroupVersionKind: gvk,
+			Data:             rawExtension.Raw,
 		})
 	}
 
 	return resources, nil
 }
 
-// InjectVarsDeployment inserts EnvVars into a Deployment doc
-func (k *KubeResource) InjectVarsDeployment(envVars []v1.EnvVar) (*v1beta1.Deployment, error) {
-	deployment := &v1beta1.Deployment{}
+// UnmarshalGeneric does not attempt to unmarshal to a known type,
+// instead returns a generic interface object for displaying to the user
+func (k *KubeResource) UnmarshalGeneric() (interface{}, error) {
+	var generic interface{}
+	if err := unmarshalUnstructured(k.Data, &generic); err != nil {
+		return generic, err
+	}
+
+	return generic, nil
+}
+
+// unmarshalUnstructured unmarshalls a doc into generic maps and slices,
+// keeping numbers as json.Number so they print back exactly as read
+func unmarshalUnstructured(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// getResourceGVK unmarshalls a file and returns the group/version/kind of
+// resource doc
+func getResourceGVK(data []byte) (unversioned.GroupVersionKind, error) {
+	typeMeta := unversioned.TypeMeta{}
+	if err := json.Unmarshal(data, &typeMeta); err != nil {
+		return unversioned.GroupVersionKind{}, err
+	}
+	return typeMeta.GroupVersionKind(), nil
+}
+
+// findPath returns every node of an unstructured doc matching a field path
+func findPath(node interface{}, fields []string) []interface{} {
+	if len(fields) == 0 {
+		return []interface{}{node}
+	}
 
-	if err := json.Unmarshal(k.Data, &deployment); err != nil {
-		return deployment, err
+	nodes := []interface{}{}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if fields[0] == "*" {
+			for _, k := range sortedKeys(n) {
+				nodes = append(nodes, findPath(n[k], fields[1:])...)
+			}
+		} else if child, ok := n[fields[0]]; ok {
+			nodes = append(nodes, findPath(child, fields[1:])...)
+		}
+	case []interface{}:
+		if fields[0] == "*" {
+			for _, child := range n {
+				nodes = append(nodes, findPath(child, fields[1:])...)
+			}
+		}
 	}
 
-	podSpec := injectPodSpecEnvVars(
-		deployment.Spec.Template.Spec,
-		envVars,
-	)
-	deployment.Spec.Template.Spec = podSpec
-	return deployment, nil
+	return nodes
+}
+
+// injectContainerEnvVars merges EnvVars into the env of an unstructured
+// container, leaving any other container fields untouched
+func injectContainerEnvVars(container map[string]interface{}, userVars []interface{}) {
+	docVars, _ := container["env"].([]interface{})
+	if mergedVars := mergeEnvVars(docVars, userVars); len(mergedVars) > 0 {
+		container["env"] = mergedVars
+	}
 }
 
-// InjectVarsDaemonSet inserts EnvVars into a daemonSet doc
-func (k *KubeResource) InjectVarsDaemonSet(envVars []v1.EnvVar) (*v1beta1.DaemonSet, error) {
-	daemonSet := &v1beta1.DaemonSet{}
+// injectContainerEnvFrom appends envFrom entries to an unstructured
+// container, keeping its existing entries
+func injectContainerEnvFrom(container map[string]interface{}, userSources []interface{}) {
+	docSources, _ := container["envFrom"].([]interface{})
+	if mergedSources := mergeEnvFrom(docSources, userSources); len(mergedSources) > 0 {
+		container["envFrom"] = mergedSources
+	}
+}
+
+// mergeEnvFrom appends the user supplied envFrom entries missing from the
+// doc entries, so later user sources take precedence on conflicting keys
+func mergeEnvFrom(docSources []interface{}, userSources []interface{}) []interface{} {
+	mergedSources := append([]interface{}{}, docSources...)
+	for _, s := range userSources {
+		if !isDuplicateEnvFrom(s, mergedSources) {
+			mergedSources = append(mergedSources, s)
+		}
+	}
+
+	return mergedSources
+}
 
-	if err := json.Unmarshal(k.Data, daemonSet); err != nil {
-		return daemonSet, err
+// checks whether an envFrom entry referencing the same source with the
+// same prefix exists in an envFrom slice
+func isDuplicateEnvFrom(s interface{}, sources []interface{}) bool {
+	for _, source := range sources {
+		if reflect.DeepEqual(envFromKey(s), envFromKey(source)) {
+			return true
+		}
 	}
+	return false
+}
 
-	podSpec := injectPodSpecEnvVars(
-		daemonSet.Spec.Template.Spec,
-		envVars,
-	)
-	daemonSet.Spec.Template.Spec = podSpec
-	return daemonSet, nil
+// envFromKey returns the identifying fields of an unstructured envFrom entry
+func envFromKey(s interface{}) []interface{} {
+	m, _ := s.(map[string]interface{})
+	key := []interface{}{m["prefix"]}
+	for _, ref := range []string{"configMapRef", "secretRef"} {
+		r, _ := m[ref].(map[string]interface{})
+		key = append(key, r["name"])
+	}
+	return key
 }
 
-// InjectVarsReplicaSet inserts EnvVars into a replicaSet doc
-func (k *KubeResource) InjectVarsReplicaSet(envVars []v1.EnvVar) (*v1beta1.ReplicaSet, error) {
-	replicaSet := &v1beta1.ReplicaSet{}
-	if err := json.Unmarshal(k.Data, replicaSet); err != nil {
-		return replicaSet, err
+// injectContainerVolumeMounts merges volumeMounts into an unstructured
+// container
+func injectContainerVolumeMounts(container map[string]interface{}, userMounts []interface{}) error {
+	if len(userMounts) == 0 {
+		return nil
 	}
 
-	podSpec := injectPodSpecEnvVars(
-		replicaSet.Spec.Template.Spec,
-		envVars,
-	)
+	docMounts, _ := container["volumeMounts"].([]interface{})
+	mergedMounts, err := mergeVolumeMounts(docMounts, userMounts)
+	if err != nil {
+		return err
+	}
 
-	replicaSet.Spec.Template.Spec = podSpec
-	return replicaSet, nil
+	container["volumeMounts"] = mergedMounts
+	return nil
 }
 
-// InjectVarsRC inserts EnvVars into a replicationController doc
-func (k *KubeResource) InjectVarsRC(envVars []v1.EnvVar) (*v1.ReplicationController, error) {
-	replicationController := &v1.ReplicationController{}
-	if err := json.Unmarshal(k.Data, replicationController); err != nil {
-		return replicationController, err
+// mergeVolumes merges user supplied volumes into the doc volumes. A doc
+// volume of the same name is updated in place when it mounts the same
+// ConfigMap or Secret, any other volume of that name is a collision.
+func mergeVolumes(docVolumes []interface{}, userVolumes []interface{}) ([]interface{}, error) {
+	mergedVolumes := append([]interface{}{}, docVolumes...)
+
+	for _, v := range userVolumes {
+		i := indexByField(mergedVolumes, "name", v)
+		if i < 0 {
+			mergedVolumes = append(mergedVolumes, v)
+			continue
+		}
+
+		if volumeSource(mergedVolumes[i]) != volumeSource(v) {
+			return mergedVolumes, fmt.Errorf("volume %s already exists with a different source", fieldString(v, "name"))
+		}
+		mergedVolumes[i] = v
 	}
 
-	podSpec := injectPodSpecEnvVars(
-		replicationController.Spec.Template.Spec,
-		envVars,
-	)
+	return mergedVolumes, nil
+}
+
+// mergeImagePullSecrets appends the user supplied imagePullSecrets that
+// the doc does not reference yet
+func mergeImagePullSecrets(docSecrets []interface{}, userSecrets []interface{}) []interface{} {
+	mergedSecrets := append([]interface{}{}, docSecrets...)
+
+	for _, s := range userSecrets {
+		if indexByField(mergedSecrets, "name", s) < 0 {
+			mergedSecrets = append(mergedSecrets, s)
+		}
+	}
 
-	replicationController.Spec.Template.Spec = podSpec
-	return replicationController, nil
+	return mergedSecrets
 }
 
-// UnmarshalGeneric does not attempt to unmarshal to a known type,
-// instead returns a generic interface object for displaying to the user
-func (k *KubeResource) UnmarshalGeneric() (interface{}, error) {
-	var generic interface{}
-	if err := json.Unmarshal(k.Data, &generic); err != nil {
-		return generic, err
+// mergeVolumeMounts merges user supplied volumeMounts into the doc
+// volumeMounts, updating mounts of the same volume in place. Mounting a
+// different volume at an existing mountPath is a collision.
+func mergeVolumeMounts(docMounts []interface{}, userMounts []interface{}) ([]interface{}, error) {
+	mergedMounts := append([]interface{}{}, docMounts...)
+
+	for _, m := range userMounts {
+		if i := indexByField(mergedMounts, "mountPath", m); i >= 0 && fieldString(mergedMounts[i], "name") != fieldString(m, "name") {
+			return mergedMounts, fmt.Errorf("volume %s is already mounted at %s", fieldString(mergedMounts[i], "name"), fieldString(m, "mountPath"))
+		}
+
+		if i := indexByField(mergedMounts, "name", m); i >= 0 {
+			mergedMounts[i] = m
+		} else {
+			mergedMounts = append(mergedMounts, m)
+		}
 	}
 
-	return generic, nil
+	return mergedMounts, nil
 }
 
-// getResourceKind unmarshalls a file and returns the kind of resource doc
-func getResourceKind(data []byte) (string, error) {
-	typeMeta := unversioned.TypeMeta{}
-	if err := json.Unmarshal(data, &typeMeta); err != nil {
-		return "", err
+// volumeSource identifies the ConfigMap or Secret mounted by an
+// unstructured volume, empty for any other volume source
+func volumeSource(v interface{}) string {
+	m, _ := v.(map[string]interface{})
+	if configMap, ok := m["configMap"]; ok {
+		return "configMap/" + fieldString(configMap, "name")
 	}
-	return typeMeta.Kind, nil
+	if secret, ok := m["secret"]; ok {
+		return "secret/" + fieldString(secret, "secretName")
+	}
+	return ""
+}
+
+// indexByField returns the index of the first unstructured entry with the
+// same string field value as e, or -1
+func indexByField(entries []interface{}, field string, e interface{}) int {
+	value := fieldString(e, field)
+	for i, entry := range entries {
+		if fieldString(entry, field) == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// fieldString returns a string field of an unstructured map
+func fieldString(m interface{}, field string) string {
+	fields, _ := m.(map[string]interface{})
+	value, _ := fields[field].(string)
+	return value
 }
 
-// creates a flattened EnvVar slice giving preference to user supplied vars
-func mergeEnvVars(docVars []v1.EnvVar, userVars []v1.EnvVar) []v1.EnvVar {
-	mergedVars := userVars
+// creates a flattened env slice giving preference to user supplied vars
+func mergeEnvVars(docVars []interface{}, userVars []interface{}) []interface{} {
+	mergedVars := append([]interface{}{}, userVars...)
 	for _, v := range docVars {
 		if !isDuplicateEnvVar(v, userVars) {
 			mergedVars = append(mergedVars, v)
@@ -143,23 +276,45 @@ func mergeEnvVars(docVars []v1.EnvVar, userVars []v1.EnvVar) []v1.EnvVar {
 	return mergedVars
 }
 
-// checks whether an EnvVar exists by name in an EnvVar slice
-func isDuplicateEnvVar(e v1.EnvVar, envVars []v1.EnvVar) bool {
+// checks whether an env entry exists by name in an env slice
+func isDuplicateEnvVar(e interface{}, envVars []interface{}) bool {
+	name := envVarName(e)
 	for _, envVar := range envVars {
-		if e.Name == envVar.Name {
+		if name == envVarName(envVar) {
 			return true
 		}
 	}
 	return false
 }
 
-// injectPodSpecEnvVars injects a slice of EnvVars into each PodSpec container
-func injectPodSpecEnvVars(podSpec v1.PodSpec, envVars []v1.EnvVar) v1.PodSpec {
-	containers := []v1.Container{}
-	for _, c := range podSpec.Containers {
-		c.Env = mergeEnvVars(c.Env, envVars)
-		containers = append(containers, c)
+// envVarName returns the name of an unstructured env entry
+func envVarName(e interface{}) string {
+	return fieldString(e, "name")
+}
+
+// toUnstructuredList converts a typed slice, e.g. of EnvVars, to its
+// unstructured form
+func toUnstructuredList(list interface{}) ([]interface{}, error) {
+	unstructured := []interface{}{}
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		return unstructured, err
+	}
+
+	err = json.Unmarshal(data, &unstructured)
+	if unstructured == nil {
+		unstructured = []interface{}{}
+	}
+	return unstructured, err
+}
+
+// sortedKeys returns the keys of an unstructured map in a stable order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
 	}
-	podSpec.Containers = containers
-	return podSpec
+	sort.Strings(keys)
+	return keys
 }
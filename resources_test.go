@@ -1,12 +1,16 @@
-package main
+package kenv
 
 import (
+	"bytes"
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"reflect"
 	"testing"
 
+	"k8s.io/kubernetes/pkg/api/unversioned"
 	"k8s.io/kubernetes/pkg/api/v1"
+	"k8s.io/kubernetes/pkg/apis/extensions/v1beta1"
 )
 
 func TestParseDocs(t *testing.T) {
@@ -27,17 +31,32 @@ func TestParseDocs(t *testing.T) {
 }
 
 func TestInjectVarsDeployment(t *testing.T) {
-	file, err := os.Open("fixtures/deployment.json")
-	defer file.Close()
-	if err != nil {
-		t.Fatal(err)
+	envVars := []v1.EnvVar{
+		v1.EnvVar{
+			Name:  "key1",
+			Value: "value1",
+		},
+		v1.EnvVar{
+			Name:  "key2",
+			Value: "value2",
+		},
 	}
 
-	resources, err := ParseDocs(file)
-	if err != nil {
+	doc := injectFixture(t, "fixtures/deployment.json", &Injection{EnvVars: envVars})
+
+	resource := &podTemplateResource{}
+	if err := remarshal(doc, resource); err != nil {
 		t.Fatal(err)
 	}
 
+	for _, c := range resource.Spec.Template.Spec.Containers {
+		if !reflect.DeepEqual(c.Env, envVars) {
+			t.Fatalf("container env vars not equal")
+		}
+	}
+}
+
+func TestInjectVarsDaemonSet(t *testing.T) {
 	envVars := []v1.EnvVar{
 		v1.EnvVar{
 			Name:  "key1",
@@ -49,30 +68,47 @@ func TestInjectVarsDeployment(t *testing.T) {
 		},
 	}
 
-	deployment, err := resources[0].InjectVarsDeployment(envVars)
-	if err != nil {
+	doc := injectFixture(t, "fixtures/daemonset.json", &Injection{EnvVars: envVars})
+
+	resource := &podTemplateResource{}
+	if err := remarshal(doc, resource); err != nil {
 		t.Fatal(err)
 	}
 
-	for _, c := range deployment.Spec.Template.Spec.Containers {
+	for _, c := range resource.Spec.Template.Spec.Containers {
 		if !reflect.DeepEqual(c.Env, envVars) {
 			t.Fatalf("container env vars not equal")
 		}
 	}
 }
 
-func TestInjectVarsDaemonSet(t *testing.T) {
-	file, err := os.Open("fixtures/deployment.json")
-	defer file.Close()
-	if err != nil {
-		t.Fatal(err)
+func TestInjectVarsReplicaSet(t *testing.T) {
+	envVars := []v1.EnvVar{
+		v1.EnvVar{
+			Name:  "key1",
+			Value: "value1",
+		},
+		v1.EnvVar{
+			Name:  "key2",
+			Value: "value2",
+		},
 	}
 
-	resources, err := ParseDocs(file)
-	if err != nil {
+	doc := injectFixture(t, "fixtures/replicaset.json", &Injection{EnvVars: envVars})
+
+	resource := &podTemplateResource{}
+	if err := remarshal(doc, resource); err != nil {
 		t.Fatal(err)
 	}
 
+	for _, c := range resource.Spec.Template.Spec.Containers {
+		if !reflect.DeepEqual(c.Env, envVars) {
+			t.Fatalf("container env vars not equal")
+		}
+	}
+}
+
+func TestInjectVarsRC(t *testing.T) {
 	envVars := []v1.EnvVar{
 		v1.EnvVar{
 			Name:  "key1",
@@ -84,30 +120,52 @@ func TestInjectVarsDaemonSet(t *testing.T) {
 		},
 	}
 
-	daemonSet, err := resources[0].InjectVarsDaemonSet(envVars)
-	if err != nil {
+	doc := injectFixture(t, "fixtures/replicationcontroller.json", &Injection{EnvVars: envVars})
+
+	resource := &podTemplateResource{}
+	if err := remarshal(doc, resource); err != nil {
 		t.Fatal(err)
 	}
 
-	for _, c := range daemonSet.Spec.Template.Spec.Containers {
+	for _, c := range resource.Spec.Template.Spec.Containers {
 		if !reflect.DeepEqual(c.Env, envVars) {
 			t.Fatalf("container env vars not equal")
 		}
 	}
 }
 
-func TestInjectVarsReplicaSet(t *testing.T) {
-	file, err := os.Open("fixtures/deployment.json")
-	defer file.Close()
-	if err != nil {
-		t.Fatal(err)
+func TestInjectVarsStatefulSet(t *testing.T) {
+	envVars := []v1.EnvVar{
+		v1.EnvVar{
+			Name:  "key1",
+			Value: "value1",
+		},
+		v1.EnvVar{
+			Name:  "key2",
+			Value: "value2",
+		},
 	}
 
-	resources, err := ParseDocs(file)
-	if err != nil {
+	doc := injectFixture(t, "fixtures/statefulset.json", &Injection{EnvVars: envVars})
+
+	resource := &podTemplateResource{}
+	if err := remarshal(doc, resource); err != nil {
 		t.Fatal(err)
 	}
 
+	for _, c := range resource.Spec.Template.Spec.Containers {
+		if !reflect.DeepEqual(c.Env, envVars) {
+			t.Fatalf("container env vars not equal")
+		}
+	}
+
+	spec := doc["spec"].(map[string]interface{})
+	if spec["serviceName"] != "nginx" || spec["volumeClaimTemplates"] == nil {
+		t.Fatalf("statefulset fields not preserved: %+v", spec)
+	}
+}
+
+func TestInjectVarsJob(t *testing.T) {
 	envVars := []v1.EnvVar{
 		v1.EnvVar{
 			Name:  "key1",
@@ -119,49 +177,154 @@ func TestInjectVarsReplicaSet(t *testing.T) {
 		},
 	}
 
-	replicaSet, err := resources[0].InjectVarsReplicaSet(envVars)
-	if err != nil {
+	doc := injectFixture(t, "fixtures/job.json", &Injection{EnvVars: envVars})
+
+	resource := &podTemplateResource{}
+	if err := remarshal(doc, resource); err != nil {
 		t.Fatal(err)
 	}
 
-	for _, c := range replicaSet.Spec.Template.Spec.Containers {
+	for _, c := range resource.Spec.Template.Spec.Containers {
 		if !reflect.DeepEqual(c.Env, envVars) {
 			t.Fatalf("container env vars not equal")
 		}
 	}
 }
 
-func TestInjectVarsRC(t *testing.T) {
-	file, err := os.Open("fixtures/deployment.json")
-	defer file.Close()
+func TestInjectVarsCronJob(t *testing.T) {
+	envVars := []v1.EnvVar{
+		v1.EnvVar{
+			Name:  "key1",
+			Value: "value1",
+		},
+	}
+
+	doc := injectFixture(t, "fixtures/cronjob.json", &Injection{EnvVars: envVars})
+
+	cronJob := struct {
+		Spec struct {
+			Schedule    string `json:"schedule"`
+			JobTemplate struct {
+				Spec v1beta1.JobSpec `json:"spec"`
+			} `json:"jobTemplate"`
+		} `json:"spec"`
+	}{}
+	if err := remarshal(doc, &cronJob); err != nil {
+		t.Fatal(err)
+	}
+
+	if cronJob.Spec.Schedule != "*/5 * * * *" {
+		t.Fatalf("schedule not preserved: %s", cronJob.Spec.Schedule)
+	}
+
+	containers := cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		t.Fatalf("no containers found in job template")
+	}
+
+	for _, c := range containers {
+		if !reflect.DeepEqual(c.Env, envVars) {
+			t.Fatalf("container env vars not equal")
+		}
+	}
+}
+
+func TestInjectVarsCronJobMissingTemplate(t *testing.T) {
+	k := &KubeResource{
+		GroupVersionKind: unversioned.FromAPIVersionAndKind("batch/v1", "CronJob"),
+		Data:             []byte(`{"kind": "CronJob", "spec": {"schedule": "@daily"}}`),
+	}
+
+	injector, _ := LookupInjector(k.GroupVersionKind)
+	doc, err := injector.Inject(k, &Injection{})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	resources, err := ParseDocs(file)
+	want, err := k.UnmarshalGeneric()
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	if !reflect.DeepEqual(want, doc) {
+		t.Fatalf("CronJob without job template not passed through; want: %+v, got: %+v", want, doc)
+	}
+}
+
+func TestInjectVarsPod(t *testing.T) {
 	envVars := []v1.EnvVar{
 		v1.EnvVar{
 			Name:  "key1",
 			Value: "value1",
 		},
+	}
+
+	doc := injectFixture(t, "fixtures/pod.json", &Injection{EnvVars: envVars})
+
+	pod := &v1.Pod{}
+	if err := remarshal(doc, pod); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range pod.Spec.Containers {
+		if !reflect.DeepEqual(c.Env, envVars) {
+			t.Fatalf("container env vars not equal")
+		}
+	}
+}
+
+func TestInjectVarsPreservesUnknownFields(t *testing.T) {
+	envVars := []v1.EnvVar{
 		v1.EnvVar{
-			Name:  "key2",
-			Value: "value2",
+			Name:  "key1",
+			Value: "value1",
 		},
 	}
 
-	replicationController, err := resources[0].InjectVarsRC(envVars)
+	doc := injectFixture(t, "fixtures/deployment-unknown-fields.yml", &Injection{EnvVars: envVars})
+
+	data, err := ioutil.ReadFile("fixtures/deployment-unknown-fields.yml")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	for _, c := range replicationController.Spec.Template.Spec.Containers {
-		if !reflect.DeepEqual(c.Env, envVars) {
-			t.Fatalf("container env vars not equal")
+	resources, err := ParseDocs(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := resources[0].UnmarshalGeneric()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the env arrays should differ from the original doc
+	containers := findPath(want, []string{"spec", "template", "spec", "containers", "*"})
+	containers[0].(map[string]interface{})["env"] = []interface{}{
+		map[string]interface{}{"name": "key1", "value": "value1"},
+		map[string]interface{}{"name": "existing", "value": "kept"},
+	}
+	containers[1].(map[string]interface{})["env"] = []interface{}{
+		map[string]interface{}{"name": "key1", "value": "value1"},
+	}
+
+	if !reflect.DeepEqual(want, doc) {
+		t.Fatalf("docs not equal; want: %+v, got: %+v", want, doc)
+	}
+}
+
+func TestInjectVarsKeepsAPIVersion(t *testing.T) {
+	for filename, want := range map[string]string{
+		"fixtures/deployment.json":               "extensions/v1beta1",
+		"fixtures/deployment-unknown-fields.yml": "apps/v1",
+		"fixtures/statefulset.json":              "apps/v1",
+		"fixtures/job.json":                      "batch/v1",
+		"fixtures/cronjob.json":                  "batch/v1",
+		"fixtures/replicationcontroller.json":    "v1",
+	} {
+		doc := injectFixture(t, filename, &Injection{})
+		if doc["apiVersion"] != want {
+			t.Fatalf("%s apiVersion not kept; want: %s, got: %v", filename, want, doc["apiVersion"])
 		}
 	}
 }
@@ -184,55 +347,60 @@ func TestUnmarshalGeneric(t *testing.T) {
 	}
 }
 
-func TestGetResourceKind(t *testing.T) {
+func TestGetResourceGVK(t *testing.T) {
 	data, err := ioutil.ReadFile("fixtures/deployment.json")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	kind, err := getResourceKind(data)
+	gvk, err := getResourceGVK(data)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if kind != "Deployment" {
-		t.Fatalf("kind not equal")
+	want := unversioned.GroupVersionKind{
+		Group:   "extensions",
+		Version: "v1beta1",
+		Kind:    "Deployment",
+	}
+	if gvk != want {
+		t.Fatalf("gvk not equal; want: %+v, got: %+v", want, gvk)
 	}
 }
 
 func TestMergeEnvVars(t *testing.T) {
-	merged := mergeEnvVars([]v1.EnvVar{
-		v1.EnvVar{
-			Name:  "dup",
-			Value: "base",
+	merged := mergeEnvVars([]interface{}{
+		map[string]interface{}{
+			"name":  "dup",
+			"value": "base",
 		},
-		v1.EnvVar{
-			Name:  "key1",
-			Value: "value1",
+		map[string]interface{}{
+			"name":  "key1",
+			"value": "value1",
 		},
-	}, []v1.EnvVar{
-		v1.EnvVar{
-			Name:  "dup",
-			Value: "overwrite",
+	}, []interface{}{
+		map[string]interface{}{
+			"name":  "dup",
+			"value": "overwrite",
 		},
-		v1.EnvVar{
-			Name:  "key2",
-			Value: "value2",
+		map[string]interface{}{
+			"name":  "key2",
+			"value": "value2",
 		},
 	})
 
-	want := []v1.EnvVar{
-		v1.EnvVar{
-			Name:  "dup",
-			Value: "overwrite",
+	want := []interface{}{
+		map[string]interface{}{
+			"name":  "dup",
+			"value": "overwrite",
 		},
-		v1.EnvVar{
-			Name:  "key2",
-			Value: "value2",
+		map[string]interface{}{
+			"name":  "key2",
+			"value": "value2",
 		},
-		v1.EnvVar{
-			Name:  "key1",
-			Value: "value1",
+		map[string]interface{}{
+			"name":  "key1",
+			"value": "value1",
 		},
 	}
 
@@ -242,13 +410,13 @@ func TestMergeEnvVars(t *testing.T) {
 }
 
 func TestIsDuplicateEnvVar(t *testing.T) {
-	d := isDuplicateEnvVar(v1.EnvVar{
-		Name:  "dup",
-		Value: "base",
-	}, []v1.EnvVar{
-		v1.EnvVar{
-			Name:  "dup",
-			Value: "overwrite",
+	d := isDuplicateEnvVar(map[string]interface{}{
+		"name":  "dup",
+		"value": "base",
+	}, []interface{}{
+		map[string]interface{}{
+			"name":  "dup",
+			"value": "overwrite",
 		},
 	})
 
@@ -256,3 +424,87 @@ func TestIsDuplicateEnvVar(t *testing.T) {
 		t.Fatalf("should be duplicate")
 	}
 }
+
+func TestMergeEnvFrom(t *testing.T) {
+	merged := mergeEnvFrom([]interface{}{
+		map[string]interface{}{
+			"configMapRef": map[string]interface{}{"name": "app"},
+		},
+	}, []interface{}{
+		map[string]interface{}{
+			"configMapRef": map[string]interface{}{"name": "app"},
+		},
+		map[string]interface{}{
+			"prefix":       "APP_",
+			"configMapRef": map[string]interface{}{"name": "app"},
+		},
+		map[string]interface{}{
+			"secretRef": map[string]interface{}{"name": "app"},
+		},
+	})
+
+	want := []interface{}{
+		map[string]interface{}{
+			"configMapRef": map[string]interface{}{"name": "app"},
+		},
+		map[string]interface{}{
+			"prefix":       "APP_",
+			"configMapRef": map[string]interface{}{"name": "app"},
+		},
+		map[string]interface{}{
+			"secretRef": map[string]interface{}{"name": "app"},
+		},
+	}
+
+	if !reflect.DeepEqual(want, merged) {
+		t.Fatalf("slices not equal; want: %+v, got: %+v", want, merged)
+	}
+}
+
+// parseFixture parses the resources of a fixture
+func parseFixture(t *testing.T, filename string) []KubeResource {
+	file, err := os.Open(filename)
+	defer file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resources, err := ParseDocs(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resources
+}
+
+// injectFixture parses a fixture and runs its registered Injector
+func injectFixture(t *testing.T, filename string, injection *Injection) map[string]interface{} {
+	resources := parseFixture(t, filename)
+	injector, ok := LookupInjector(resources[0].GroupVersionKind)
+	if !ok {
+		t.Fatalf("no injector for %s", resources[0].Kind)
+	}
+
+	result, err := injector.Inject(&resources[0], injection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return result.(map[string]interface{})
+}
+
+// podTemplateResource is the spec.template.spec layout shared by most kinds
+type podTemplateResource struct {
+	Spec struct {
+		Template v1.PodTemplateSpec `json:"template"`
+	} `json:"spec"`
+}
+
+// remarshal converts a generic doc into a typed struct for inspection
+func remarshal(in interface{}, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,200 @@
+package kenv
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/api/v1"
+)
+
+// SealingScope restricts the name and namespace a SealedSecret can be
+// unsealed as
+type SealingScope string
+
+// sealing scopes of the sealed-secrets controller
+const (
+	ScopeStrict        SealingScope = "strict"
+	ScopeNamespaceWide SealingScope = "namespace-wide"
+	ScopeClusterWide   SealingScope = "cluster-wide"
+)
+
+// scopeAnnotations mark the non-strict scopes on SealedSecrets
+var scopeAnnotations = map[SealingScope]string{
+	ScopeNamespaceWide: "sealedsecrets.bitnami.com/namespace-wide",
+	ScopeClusterWide:   "sealedsecrets.bitnami.com/cluster-wide",
+}
+
+// sealedSecretSessionKeySize is the AES-256 key size of each sealed value
+const sealedSecretSessionKeySize = 32
+
+// SealedSecret is a bitnami.com/v1alpha1 SealedSecret resource
+type SealedSecret struct {
+	unversioned.TypeMeta `json:",inline"`
+	ObjectMeta           v1.ObjectMeta    `json:"metadata"`
+	Spec                 SealedSecretSpec `json:"spec"`
+}
+
+// SealedSecretSpec holds the encrypted data and the template of the Secret
+// the controller unseals
+type SealedSecretSpec struct {
+	Template      SecretTemplate    `json:"template"`
+	EncryptedData map[string]string `json:"encryptedData"`
+}
+
+// SecretTemplate is the metadata and type of an unsealed Secret
+type SecretTemplate struct {
+	ObjectMeta v1.ObjectMeta `json:"metadata"`
+	Type       v1.SecretType `json:"type,omitempty"`
+}
+
+// parseSealingScope parses a sealing scope, strict when empty
+func parseSealingScope(value string) (SealingScope, error) {
+	switch scope := SealingScope(value); scope {
+	case "":
+		return ScopeStrict, nil
+	case ScopeStrict, ScopeNamespaceWide, ScopeClusterWide:
+		return scope, nil
+	}
+
+	return "", fmt.Errorf("%s is not a sealing scope, must be one of %s, %s or %s", value, ScopeStrict, ScopeNamespaceWide, ScopeClusterWide)
+}
+
+// readSealingKey reads the RSA public key of a sealed-secrets certificate,
+// as printed by kubeseal --fetch-cert, or of a PEM public key
+func readSealingKey(filename string) (*rsa.PublicKey, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not PEM encoded", filename)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+		key = cert.PublicKey
+	case "PUBLIC KEY":
+		if key, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unexpected PEM block %s", filename, block.Type)
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s does not hold an RSA public key", filename)
+	}
+
+	return rsaKey, nil
+}
+
+// sealSecret encrypts each value of a Secret for the sealed-secrets
+// controller owning key, keeping its name, namespace and type
+func sealSecret(secret *v1.Secret, key *rsa.PublicKey, scope SealingScope, random io.Reader) (*SealedSecret, error) {
+	meta := v1.ObjectMeta{
+		Name:      secret.Name,
+		Namespace: secret.Namespace,
+	}
+	if annotation, ok := scopeAnnotations[scope]; ok {
+		meta.Annotations = map[string]string{annotation: "true"}
+	}
+
+	sealed := &SealedSecret{
+		TypeMeta: unversioned.TypeMeta{
+			Kind:       "SealedSecret",
+			APIVersion: "bitnami.com/v1alpha1",
+		},
+		ObjectMeta: meta,
+		Spec: SealedSecretSpec{
+			Template: SecretTemplate{
+				ObjectMeta: meta,
+				Type:       secret.Type,
+			},
+			EncryptedData: map[string]string{},
+		},
+	}
+
+	label := sealingLabel(secret, scope)
+	for _, k := range sortedSecretKeys(secret.Data) {
+		ciphertext, err := hybridEncrypt(random, key, secret.Data[k], label)
+		if err != nil {
+			return nil, fmt.Errorf("sealing %s: %s", k, err)
+		}
+		sealed.Spec.EncryptedData[k] = base64.StdEncoding.EncodeToString(ciphertext)
+	}
+
+	return sealed, nil
+}
+
+// sealingLabel binds sealed values to the Secret namespace and name, or
+// only its namespace, as allowed by the scope
+func sealingLabel(secret *v1.Secret, scope SealingScope) []byte {
+	switch scope {
+	case ScopeClusterWide:
+		return []byte{}
+	case ScopeNamespaceWide:
+		return []byte(secret.Namespace)
+	}
+	return []byte(secret.Namespace + "/" + secret.Name)
+}
+
+// hybridEncrypt encrypts plaintext with a random AES-GCM session key, which
+// is itself encrypted with RSA-OAEP. The output is the 2 byte length of the
+// RSA ciphertext, the RSA ciphertext and the AES-GCM ciphertext.
+func hybridEncrypt(random io.Reader, key *rsa.PublicKey, plaintext, label []byte) ([]byte, error) {
+	sessionKey := make([]byte, sealedSecretSessionKeySize)
+	if _, err := io.ReadFull(random, sessionKey); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaCiphertext, err := rsa.EncryptOAEP(sha256.New(), random, key, sessionKey, label)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext := make([]byte, 2, 2+len(rsaCiphertext)+len(plaintext)+gcm.Overhead())
+	binary.BigEndian.PutUint16(ciphertext, uint16(len(rsaCiphertext)))
+	ciphertext = append(ciphertext, rsaCiphertext...)
+
+	// every value has its own session key, so a zero nonce is never reused
+	return gcm.Seal(ciphertext, make([]byte, gcm.NonceSize()), plaintext, nil), nil
+}
+
+// sortedSecretKeys returns the keys of Secret data in order
+func sortedSecretKeys(data map[string][]byte) []string {
+	keys := []string{}
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
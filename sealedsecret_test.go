@@ -0,0 +1,126 @@
+package kenv
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+)
+
+// hybridDecrypt reverses hybridEncrypt, as the sealed-secrets controller does
+func hybridDecrypt(key *rsa.PrivateKey, ciphertext, label []byte) ([]byte, error) {
+	n := int(binary.BigEndian.Uint16(ciphertext))
+	sessionKey, err := rsa.DecryptOAEP(sha256.New(), nil, key, ciphertext[2:2+n], label)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return gcm.Open(nil, make([]byte, gcm.NonceSize()), ciphertext[2+n:], nil)
+}
+
+func TestSealSecret(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, secret, err := Vars{
+		Var{Key: "password", Value: "s3cr3t"},
+	}.toSecret("nginx", "web", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret.Type = secretTypeBasicAuth
+
+	tests := []struct {
+		scope      SealingScope
+		label      string
+		annotation string
+	}{
+		{ScopeStrict, "web/nginx", ""},
+		{ScopeNamespaceWide, "web", "sealedsecrets.bitnami.com/namespace-wide"},
+		{ScopeClusterWide, "", "sealedsecrets.bitnami.com/cluster-wide"},
+	}
+
+	for _, test := range tests {
+		sealed, err := sealSecret(secret, &key.PublicKey, test.scope, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if sealed.Kind != "SealedSecret" || sealed.APIVersion != "bitnami.com/v1alpha1" {
+			t.Fatalf("unexpected type meta: %+v", sealed.TypeMeta)
+		}
+		if sealed.ObjectMeta.Name != "nginx" || sealed.ObjectMeta.Namespace != "web" || sealed.Spec.Template.ObjectMeta.Name != "nginx" {
+			t.Fatalf("unexpected metadata: %+v", sealed)
+		}
+		if sealed.Spec.Template.Type != secretTypeBasicAuth {
+			t.Fatalf("expected template type %s, got %s", secretTypeBasicAuth, sealed.Spec.Template.Type)
+		}
+		if test.annotation != "" && sealed.ObjectMeta.Annotations[test.annotation] != "true" {
+			t.Fatalf("%s: expected annotation %s, got %+v", test.scope, test.annotation, sealed.ObjectMeta.Annotations)
+		}
+
+		ciphertext, err := base64.StdEncoding.DecodeString(sealed.Spec.EncryptedData["password"])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		plaintext, err := hybridDecrypt(key, ciphertext, []byte(test.label))
+		if err != nil || string(plaintext) != "s3cr3t" {
+			t.Fatalf("%s: unsealing failed: %q, %v", test.scope, plaintext, err)
+		}
+
+		if test.label != "" {
+			if _, err := hybridDecrypt(key, ciphertext, []byte("other/nginx")); err == nil {
+				t.Fatalf("%s: unsealed with another label", test.scope)
+			}
+		}
+	}
+}
+
+func TestReadSealingKey(t *testing.T) {
+	key, err := readSealingKey("fixtures/sealed-secrets.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.N.BitLen() != 2048 {
+		t.Fatalf("expected a 2048 bit key, got %d", key.N.BitLen())
+	}
+
+	if _, err := readSealingKey("fixtures/tls/tls.crt"); err == nil {
+		t.Fatal("expected error for a non-RSA certificate")
+	}
+}
+
+func TestParseSealingScope(t *testing.T) {
+	for value, want := range map[string]SealingScope{
+		"":               ScopeStrict,
+		"strict":         ScopeStrict,
+		"namespace-wide": ScopeNamespaceWide,
+		"cluster-wide":   ScopeClusterWide,
+	} {
+		got, err := parseSealingScope(value)
+		if err != nil || got != want {
+			t.Fatalf("parsing %q: want %s, got %s, %v", value, want, got, err)
+		}
+	}
+
+	if _, err := parseSealingScope("global"); err == nil {
+		t.Fatal("expected error for unknown scope")
+	}
+}
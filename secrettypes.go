@@ -0,0 +1,161 @@
+package kenv
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/kubernetes/pkg/api/v1"
+)
+
+// Secret types missing from the vendored v1 types
+const (
+	secretTypeDockerConfigJSON v1.SecretType = "kubernetes.io/dockerconfigjson"
+	secretTypeBasicAuth        v1.SecretType = "kubernetes.io/basic-auth"
+	secretTypeSSHAuth          v1.SecretType = "kubernetes.io/ssh-auth"
+)
+
+// defaultTLSMountPath is where TLS Secrets are mounted without -secret-mount
+const defaultTLSMountPath = "/etc/tls"
+
+// secretTypeNames maps the short names accepted by -secret-type to types
+var secretTypeNames = map[string]v1.SecretType{
+	"opaque":          v1.SecretTypeOpaque,
+	"tls":             v1.SecretTypeTLS,
+	"docker-registry": secretTypeDockerConfigJSON,
+	"basic-auth":      secretTypeBasicAuth,
+	"ssh-auth":        secretTypeSSHAuth,
+}
+
+// Keys of the docker-registry credentials kenv builds a
+// .dockerconfigjson from, named after the kubectl create secret flags
+const (
+	dockerServerKey   = "docker-server"
+	dockerUsernameKey = "docker-username"
+	dockerPasswordKey = "docker-password"
+	dockerEmailKey    = "docker-email"
+	dockerConfigKey   = ".dockerconfigjson"
+)
+
+// parseSecretType parses a short name or full Secret type, Opaque when empty
+func parseSecretType(value string) (v1.SecretType, error) {
+	if value == "" {
+		return v1.SecretTypeOpaque, nil
+	}
+
+	for n, t := range secretTypeNames {
+		if value == n || value == string(t) {
+			return t, nil
+		}
+	}
+
+	names := []string{}
+	for n := range secretTypeNames {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return "", fmt.Errorf("%s is not a supported Secret type, must be one of %s", value, strings.Join(names, ", "))
+}
+
+// typedSecretVars validates that vars hold the keys required by a Secret
+// type, building the .dockerconfigjson of docker-registry Secrets from the
+// docker-server, docker-username, docker-password and docker-email keys.
+// docker-registry Secrets may not hold any other keys.
+func typedSecretVars(secretType v1.SecretType, vars Vars) (Vars, error) {
+	values := map[string]string{}
+	for _, v := range vars {
+		values[v.Key] = v.Value
+	}
+
+	require := func(keys ...string) error {
+		for _, key := range keys {
+			if _, ok := values[key]; !ok {
+				return fmt.Errorf("%s Secret requires key %s", secretType, key)
+			}
+		}
+		return nil
+	}
+
+	allow := func(keys ...string) error {
+		allowed := map[string]bool{}
+		for _, key := range keys {
+			allowed[key] = true
+		}
+		for _, v := range vars {
+			if !allowed[v.Key] {
+				return fmt.Errorf("%s Secret has unexpected key %s, must be one of %s", secretType, v.Key, strings.Join(keys, ", "))
+			}
+		}
+		return nil
+	}
+
+	switch secretType {
+	case v1.SecretTypeTLS:
+		if err := require(v1.TLSCertKey, v1.TLSPrivateKeyKey); err != nil {
+			return vars, err
+		}
+		if _, err := tls.X509KeyPair([]byte(values[v1.TLSCertKey]), []byte(values[v1.TLSPrivateKeyKey])); err != nil {
+			return vars, fmt.Errorf("%s Secret has an invalid certificate or key: %s", secretType, err)
+		}
+	case secretTypeDockerConfigJSON:
+		if _, ok := values[dockerConfigKey]; ok {
+			if err := allow(dockerConfigKey); err != nil {
+				return vars, err
+			}
+			config := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(values[dockerConfigKey]), &config); err != nil {
+				return vars, fmt.Errorf("%s Secret has an invalid %s: %s", secretType, dockerConfigKey, err)
+			}
+			if _, ok := config["auths"].(map[string]interface{}); !ok {
+				return vars, fmt.Errorf("%s Secret has no auths in %s", secretType, dockerConfigKey)
+			}
+			return Vars{Var{Key: dockerConfigKey, Value: values[dockerConfigKey]}}, nil
+		}
+
+		if err := require(dockerServerKey, dockerUsernameKey, dockerPasswordKey); err != nil {
+			return vars, err
+		}
+		if err := allow(dockerServerKey, dockerUsernameKey, dockerPasswordKey, dockerEmailKey); err != nil {
+			return vars, err
+		}
+		config, err := dockerConfigJSON(values[dockerServerKey], values[dockerUsernameKey], values[dockerPasswordKey], values[dockerEmailKey])
+		if err != nil {
+			return vars, err
+		}
+		return Vars{Var{Key: dockerConfigKey, Value: config}}, nil
+	case secretTypeBasicAuth:
+		_, username := values["username"]
+		_, password := values["password"]
+		if !username && !password {
+			return vars, fmt.Errorf("%s Secret requires key username or password", secretType)
+		}
+	case secretTypeSSHAuth:
+		if err := require("ssh-privatekey"); err != nil {
+			return vars, err
+		}
+	}
+
+	return vars, nil
+}
+
+// dockerConfigJSON builds a .dockerconfigjson for a single registry, like
+// kubectl create secret docker-registry
+func dockerConfigJSON(server, username, password, email string) (string, error) {
+	auth := map[string]string{
+		"username": username,
+		"password": password,
+		"auth":     base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+	}
+	if email != "" {
+		auth["email"] = email
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{server: auth},
+	})
+	return string(data), err
+}
@@ -0,0 +1,152 @@
+package kenv
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"k8s.io/kubernetes/pkg/api/v1"
+)
+
+func TestParseSecretType(t *testing.T) {
+	tests := map[string]v1.SecretType{
+		"":                               v1.SecretTypeOpaque,
+		"tls":                            v1.SecretTypeTLS,
+		"kubernetes.io/tls":              v1.SecretTypeTLS,
+		"docker-registry":                secretTypeDockerConfigJSON,
+		"basic-auth":                     secretTypeBasicAuth,
+		"kubernetes.io/ssh-auth":         secretTypeSSHAuth,
+		"kubernetes.io/basic-auth":       secretTypeBasicAuth,
+		"kubernetes.io/dockerconfigjson": secretTypeDockerConfigJSON,
+	}
+
+	for value, want := range tests {
+		got, err := parseSecretType(value)
+		if err != nil || got != want {
+			t.Fatalf("parsing %q: want %s, got %s, %v", value, want, got, err)
+		}
+	}
+
+	if _, err := parseSecretType("kubernetes.io/dockercfg"); err == nil {
+		t.Fatal("expected error for unsupported type")
+	}
+}
+
+func TestTypedSecretVarsTLS(t *testing.T) {
+	vars, err := readVarsFile("fixtures/tls", VarsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := typedSecretVars(v1.SecretTypeTLS, vars); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := typedSecretVars(v1.SecretTypeTLS, vars[:1]); err == nil || !strings.Contains(err.Error(), "requires key tls.key") {
+		t.Fatalf("expected missing tls.key error, got %v", err)
+	}
+
+	invalid := Vars{
+		Var{Key: "tls.crt", Value: vars[0].Value},
+		Var{Key: "tls.key", Value: "not a key"},
+	}
+	if _, err := typedSecretVars(v1.SecretTypeTLS, invalid); err == nil {
+		t.Fatal("expected invalid key pair error")
+	}
+}
+
+func TestTypedSecretVarsDockerRegistry(t *testing.T) {
+	vars, err := typedSecretVars(secretTypeDockerConfigJSON, Vars{
+		Var{Key: "docker-server", Value: "registry.example.com"},
+		Var{Key: "docker-username", Value: "ci"},
+		Var{Key: "docker-password", Value: "hunter2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(vars) != 1 || vars[0].Key != ".dockerconfigjson" {
+		t.Fatalf("expected a single .dockerconfigjson var, got %+v", vars)
+	}
+
+	config := struct {
+		Auths map[string]map[string]string
+	}{}
+	if err := json.Unmarshal([]byte(vars[0].Value), &config); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := config.Auths["registry.example.com"]
+	if auth["username"] != "ci" || auth["password"] != "hunter2" || auth["auth"] != "Y2k6aHVudGVyMg==" {
+		t.Fatalf("unexpected auth: %+v", auth)
+	}
+
+	// a prebuilt .dockerconfigjson is kept as is
+	prebuilt := Vars{Var{Key: ".dockerconfigjson", Value: vars[0].Value}}
+	if got, err := typedSecretVars(secretTypeDockerConfigJSON, prebuilt); err != nil || got[0].Value != vars[0].Value {
+		t.Fatalf("expected prebuilt .dockerconfigjson, got %+v, %v", got, err)
+	}
+
+	if _, err := typedSecretVars(secretTypeDockerConfigJSON, Vars{Var{Key: "docker-server", Value: "x"}}); err == nil {
+		t.Fatal("expected missing credentials error")
+	}
+
+	// other keys would be silently dropped
+	unexpected := append(Vars{Var{Key: "docker-user", Value: "ci"}}, prebuilt...)
+	if _, err := typedSecretVars(secretTypeDockerConfigJSON, unexpected); err == nil {
+		t.Fatal("expected unexpected key error")
+	}
+	unexpected = Vars{
+		Var{Key: "docker-server", Value: "registry.example.com"},
+		Var{Key: "docker-username", Value: "ci"},
+		Var{Key: "docker-password", Value: "hunter2"},
+		Var{Key: "docker-token", Value: "abc"},
+	}
+	if _, err := typedSecretVars(secretTypeDockerConfigJSON, unexpected); err == nil || !strings.Contains(err.Error(), "unexpected key docker-token") {
+		t.Fatalf("expected unexpected key error, got %v", err)
+	}
+}
+
+func TestTypedSecretVarsRequiredKeys(t *testing.T) {
+	tests := []struct {
+		secretType v1.SecretType
+		vars       Vars
+		valid      bool
+	}{
+		{secretTypeBasicAuth, Vars{Var{Key: "username", Value: "admin"}}, true},
+		{secretTypeBasicAuth, Vars{Var{Key: "user", Value: "admin"}}, false},
+		{secretTypeSSHAuth, Vars{Var{Key: "ssh-privatekey", Value: "key"}}, true},
+		{secretTypeSSHAuth, Vars{Var{Key: "id_rsa", Value: "key"}}, false},
+		{v1.SecretTypeOpaque, Vars{Var{Key: "anything", Value: "goes"}}, true},
+	}
+
+	for _, test := range tests {
+		_, err := typedSecretVars(test.secretType, test.vars)
+		if (err == nil) != test.valid {
+			t.Fatalf("%s %+v: expected valid %t, got %v", test.secretType, test.vars, test.valid, err)
+		}
+	}
+}
+
+func TestInjectionImagePullSecrets(t *testing.T) {
+	injection := &Injection{
+		Renames: []ResourceRename{
+			ResourceRename{Kind: "Secret", Name: "regcred", NewName: "regcred-0123456789"},
+		},
+	}
+	injection.addImagePullSecret("regcred-0123456789")
+	injection.addImagePullSecret("quay")
+
+	doc := injectFixture(t, "fixtures/deployment-pull-secrets.yml", injection)
+
+	names := findPath(doc, []string{"spec", "template", "spec", "imagePullSecrets", "*", "name"})
+	got := []string{}
+	for _, n := range names {
+		got = append(got, n.(string))
+	}
+
+	want := "dockerhub,regcred-0123456789,quay"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("expected imagePullSecrets %s, got %s", want, strings.Join(got, ","))
+	}
+}
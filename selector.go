@@ -0,0 +1,104 @@
+package kenv
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"k8s.io/kubernetes/pkg/labels"
+)
+
+// ResourceSelector selects the resources of a multi-document stream to
+// inject into. Empty fields match every resource.
+type ResourceSelector struct {
+	// Names are resource names or globs
+	Names []string
+
+	// Kinds are kinds, optionally qualified as apiVersion/Kind
+	Kinds []string
+
+	// Labels is a label selector matched against metadata.labels
+	Labels labels.Selector
+}
+
+// newResourceSelector creates a ResourceSelector validating the name globs
+// and parsing the label selector
+func newResourceSelector(names []string, kinds []string, selector string) (*ResourceSelector, error) {
+	for _, name := range names {
+		if _, err := path.Match(name, ""); err != nil {
+			return nil, fmt.Errorf("%s is not a valid name selector: %s", name, err)
+		}
+	}
+
+	labelSelector, err := labels.Parse(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResourceSelector{
+		Names:  names,
+		Kinds:  kinds,
+		Labels: labelSelector,
+	}, nil
+}
+
+// Matches checks whether a resource is selected
+func (s *ResourceSelector) Matches(k *KubeResource) (bool, error) {
+	if !s.matchesKind(k) {
+		return false, nil
+	}
+
+	// only the name and labels are read, so other metadata fields the
+	// vendored types cannot decode do not matter
+	doc := map[string]interface{}{}
+	if err := unmarshalUnstructured(k.Data, &doc); err != nil {
+		return false, err
+	}
+
+	metadata, _ := doc["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	if !s.matchesName(name) {
+		return false, nil
+	}
+
+	set := labels.Set{}
+	values, _ := metadata["labels"].(map[string]interface{})
+	for key, value := range values {
+		if value, ok := value.(string); ok {
+			set[key] = value
+		}
+	}
+
+	return s.Labels == nil || s.Labels.Matches(set), nil
+}
+
+// matchesKind checks the resource against the selected kinds
+func (s *ResourceSelector) matchesKind(k *KubeResource) bool {
+	if len(s.Kinds) == 0 {
+		return true
+	}
+
+	apiVersion, kind := k.ToAPIVersionAndKind()
+	for _, selected := range s.Kinds {
+		if strings.EqualFold(selected, kind) || strings.EqualFold(selected, apiVersion+"/"+kind) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesName checks a resource name against the selected names
+func (s *ResourceSelector) matchesName(name string) bool {
+	if len(s.Names) == 0 {
+		return true
+	}
+
+	for _, pattern := range s.Names {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
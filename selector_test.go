@@ -0,0 +1,84 @@
+package kenv
+
+import (
+	"os"
+	"testing"
+)
+
+func TestResourceSelectorMatches(t *testing.T) {
+	file, err := os.Open("fixtures/workers.yml")
+	defer file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resources, err := ParseDocs(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		names    []string
+		kinds    []string
+		selector string
+		want     []bool
+	}{
+		{nil, nil, "", []bool{true, true, true}},
+		{[]string{"worker"}, nil, "", []bool{false, true, false}},
+		{[]string{"api", "clean*"}, nil, "", []bool{true, false, true}},
+		{nil, []string{"deployment"}, "", []bool{true, true, false}},
+		{nil, []string{"batch/v1/CronJob"}, "", []bool{false, false, true}},
+		{nil, []string{"apps/v1beta1/Deployment"}, "", []bool{false, false, false}},
+		{nil, nil, "tier=backend", []bool{false, true, true}},
+		{nil, nil, "tier!=backend,app=shop", []bool{true, false, false}},
+		{nil, []string{"Deployment"}, "tier in (backend)", []bool{false, true, false}},
+	}
+
+	for _, test := range tests {
+		selector, err := newResourceSelector(test.names, test.kinds, test.selector)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, resource := range resources {
+			got, err := selector.Matches(&resource)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != test.want[i] {
+				t.Fatalf("%+v matching resource %d: want %t, got %t", test, i, test.want[i], got)
+			}
+		}
+	}
+}
+
+func TestResourceSelectorMatchesUnstructured(t *testing.T) {
+	// metadata the v1 types cannot decode is ignored
+	resource := KubeResource{
+		Data: []byte(`{"kind": "Deployment", "metadata": {"name": "api", "creationTimestamp": "yesterday", "labels": {"tier": "backend", "replicas": 3}}}`),
+	}
+
+	selector, err := newResourceSelector([]string{"api"}, nil, "tier=backend")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := selector.Matches(&resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got {
+		t.Fatalf("expected %s to match", resource.Data)
+	}
+}
+
+func TestNewResourceSelectorInvalid(t *testing.T) {
+	if _, err := newResourceSelector(nil, nil, "tier in backend"); err == nil {
+		t.Fatalf("expected error for invalid label selector")
+	}
+
+	if _, err := newResourceSelector([]string{"[a-"}, nil, ""); err == nil {
+		t.Fatalf("expected error for invalid name glob")
+	}
+}
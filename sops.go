@@ -0,0 +1,243 @@
+package kenv
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/aes"
+	"github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/keyservice"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// sopsMetadataKey holds the SOPS metadata of encrypted YAML and JSON files
+const sopsMetadataKey = "sops"
+
+// sopsDotenvPrefix prefixes the flattened SOPS metadata of dotenv files
+const sopsDotenvPrefix = "sops_"
+
+// isSOPSTree returns true if a decoded YAML or JSON file has SOPS metadata
+func isSOPSTree(config interface{}) bool {
+	tree, ok := config.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	metadata, ok := tree[sopsMetadataKey].(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	_, mac := metadata["mac"]
+	_, version := metadata["version"]
+	return mac || version
+}
+
+// isSOPSVars returns true if dotenv vars have SOPS metadata
+func isSOPSVars(vars Vars) bool {
+	for _, v := range vars {
+		if v.Key == sopsDotenvPrefix+"mac" || v.Key == sopsDotenvPrefix+"version" {
+			return true
+		}
+	}
+	return false
+}
+
+// decryptSOPS decrypts a SOPS file of format yaml, json or dotenv in memory.
+// SOPS verifies the MAC of the file, so values that were edited, swapped or
+// added in plaintext are rejected.
+func decryptSOPS(data []byte, format string, opts VarsOptions) ([]byte, error) {
+	store := common.StoreForFormat(formats.FormatFromString(format))
+	tree, err := store.LoadEncryptedFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// SOPS panics on values it should decrypt that are not strings
+	if err = checkSOPSValues(tree); err != nil {
+		return nil, err
+	}
+
+	var svc keyservice.KeyServiceClient = keyservice.NewLocalClient()
+	if opts.AgeKeyFile != "" {
+		if svc, err = newAgeKeyService(opts.AgeKeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = common.DecryptTree(common.DecryptTreeOpts{
+		Tree:        &tree,
+		KeyServices: []keyservice.KeyServiceClient{svc},
+		Cipher:      aes.NewCipher(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return store.EmitPlainFile(tree.Branches)
+}
+
+// ageKeyService decrypts age data keys with the identities of a key file,
+// and other data keys like the sops command does
+type ageKeyService struct {
+	keyservice.LocalClient
+	identities age.ParsedIdentities
+}
+
+// newAgeKeyService returns an ageKeyService with the identities of filename
+func newAgeKeyService(filename string) (*ageKeyService, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := &ageKeyService{LocalClient: keyservice.NewLocalClient()}
+	if err = svc.identities.Import(string(data)); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	return svc, nil
+}
+
+// Decrypt decrypts age data keys with the identities of the key file only
+func (s *ageKeyService) Decrypt(ctx context.Context, req *keyservice.DecryptRequest, opts ...grpc.CallOption) (*keyservice.DecryptResponse, error) {
+	recipient := req.GetKey().GetAgeKey()
+	if recipient == nil {
+		return s.LocalClient.Decrypt(ctx, req, opts...)
+	}
+
+	key := &age.MasterKey{
+		Recipient:    recipient.Recipient,
+		EncryptedKey: string(req.Ciphertext),
+	}
+	s.identities.ApplyToMasterKey(key)
+
+	plaintext, err := key.Decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	return &keyservice.DecryptResponse{Plaintext: plaintext}, nil
+}
+
+// checkSOPSValues returns an error for values of tree that SOPS would
+// decrypt but are not strings, e.g. debug: true added in plaintext
+func checkSOPSValues(tree sops.Tree) error {
+	for _, branch := range tree.Branches {
+		if err := checkSOPSBranch(tree.Metadata, branch, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSOPSBranch checks the values of branch under path
+func checkSOPSBranch(metadata sops.Metadata, branch sops.TreeBranch, path []string) error {
+	for _, item := range branch {
+		key, ok := item.Key.(string)
+		if !ok {
+			continue
+		}
+
+		if err := checkSOPSValue(metadata, item.Value, append(path, key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSOPSValue checks a value of a tree at path, recursing into branches
+// and lists
+func checkSOPSValue(metadata sops.Metadata, value interface{}, path []string) error {
+	switch v := value.(type) {
+	case sops.TreeBranch:
+		return checkSOPSBranch(metadata, v, path)
+	case []interface{}:
+		for _, e := range v {
+			if err := checkSOPSValue(metadata, e, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	case string, sops.Comment, nil:
+		return nil
+	}
+
+	if isSOPSEncrypted(metadata, path) {
+		return fmt.Errorf("%s: value is not encrypted", strings.Join(path, "."))
+	}
+	return nil
+}
+
+// isSOPSEncrypted returns true if SOPS encrypts the value at path, following
+// the unencrypted and encrypted suffixes and regexes of metadata
+func isSOPSEncrypted(metadata sops.Metadata, path []string) bool {
+	encrypted := true
+	if metadata.UnencryptedSuffix != "" && anyKey(path, func(k string) bool {
+		return strings.HasSuffix(k, metadata.UnencryptedSuffix)
+	}) {
+		encrypted = false
+	}
+	if metadata.EncryptedSuffix != "" {
+		encrypted = anyKey(path, func(k string) bool {
+			return strings.HasSuffix(k, metadata.EncryptedSuffix)
+		})
+	}
+	if metadata.UnencryptedRegex != "" && anyKey(path, func(k string) bool {
+		matched, _ := regexp.MatchString(metadata.UnencryptedRegex, k)
+		return matched
+	}) {
+		encrypted = false
+	}
+	if metadata.EncryptedRegex != "" {
+		encrypted = anyKey(path, func(k string) bool {
+			matched, _ := regexp.MatchString(metadata.EncryptedRegex, k)
+			return matched
+		})
+	}
+	return encrypted
+}
+
+// anyKey returns true if match returns true for a key of path
+func anyKey(path []string, match func(string) bool) bool {
+	for _, k := range path {
+		if match(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSOPSDotenv parses decrypted dotenv data the way SOPS writes it: one
+// unquoted key=value per line, with newlines in values escaped as \n
+func parseSOPSDotenv(data []byte) (Vars, error) {
+	vars := Vars{}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		pos := bytes.IndexByte(line, '=')
+		if pos < 0 {
+			continue
+		}
+
+		v, err := decodeVar(Var{
+			Key:   string(line[:pos]),
+			Value: strings.Replace(string(line[pos+1:]), `\n`, "\n", -1),
+		})
+		if err != nil {
+			return vars, err
+		}
+		vars = append(vars, v)
+	}
+
+	return vars, nil
+}
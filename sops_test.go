@@ -0,0 +1,128 @@
+package kenv
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadSOPSVars(t *testing.T) {
+	want := Vars{
+		Var{Key: "db_host", Value: "db.example.com"},
+		Var{Key: "db_password", Value: "s3cr3t"},
+		Var{Key: "db_port", Value: "5432"},
+		Var{Key: "db_replicas_0", Value: "db-1.example.com"},
+		Var{Key: "db_replicas_1", Value: "db-2.example.com"},
+		Var{Key: "debug", Value: "true"},
+		Var{Key: "region_unencrypted", Value: "us-east-1"},
+	}
+
+	opts := VarsOptions{AgeKeyFile: "fixtures/sops/age.txt"}
+
+	for _, filename := range []string{"fixtures/sops/secrets.enc.yaml", "fixtures/sops/secrets.enc.json"} {
+		vars, err := readVarsFile(filename, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(want, vars) {
+			t.Fatalf("%s: not equal, wanted: %+v, got: %+v", filename, want, vars)
+		}
+	}
+}
+
+func TestReadSOPSDotenv(t *testing.T) {
+	want := Vars{
+		Var{Key: "DB_HOST", Value: "db.example.com"},
+		Var{Key: "DB_PASSWORD", Value: "s3cr3t"},
+		Var{Key: "TLS_KEY", Value: "line1\nline2"},
+	}
+
+	vars, err := readVarsFile("fixtures/sops/secrets.enc.env", VarsOptions{AgeKeyFile: "fixtures/sops/age.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(want, vars) {
+		t.Fatalf("not equal, wanted: %+v, got: %+v", want, vars)
+	}
+}
+
+func TestReadSOPSVarsErrors(t *testing.T) {
+	// a valid identity that is not a recipient of the fixtures
+	os.Setenv("SOPS_AGE_KEY", "AGE-SECRET-KEY-1CKWQ9Y7A054SWLUREADQ20TJSVSV7G06GKTFTEDQJGTF25X4MCVQ36R5C2")
+	defer os.Unsetenv("SOPS_AGE_KEY")
+
+	_, err := readVarsFile("fixtures/sops/secrets.enc.yaml", VarsOptions{})
+	if err == nil || !strings.Contains(err.Error(), "data key") {
+		t.Fatalf("expected data key error, got %v", err)
+	}
+
+	// the identities of -sops-age-key-file are used instead of the environment
+	opts := VarsOptions{AgeKeyFile: "fixtures/sops/age.txt"}
+	if _, err = readVarsFile("fixtures/sops/secrets.enc.yaml", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	yamlData, err := ioutil.ReadFile("fixtures/sops/secrets.enc.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dotenvData, err := ioutil.ReadFile("fixtures/sops/secrets.enc.env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(dotenvData), "\n")
+	host, password := lines[0][len("DB_HOST="):], lines[1][len("DB_PASSWORD="):]
+
+	dir, err := ioutil.TempDir("", "kenv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			// the MAC covers unencrypted values too
+			name: "secrets.enc.yaml",
+			data: strings.Replace(string(yamlData), "us-east-1", "eu-west-1", 1),
+			err:  "MAC mismatch",
+		},
+		{
+			name: "secrets.enc.yaml",
+			data: strings.Replace(string(yamlData), "debug: ENC[", "admin: true\ndebug: ENC[", 1),
+			err:  "admin: value is not encrypted",
+		},
+		{
+			name: "secrets.enc.env",
+			data: "DB_HOST=evil.example.com\n" + strings.Join(lines[1:], "\n"),
+			err:  "Could not decrypt value",
+		},
+		{
+			// values are authenticated with their key, so swapping them fails
+			name: "secrets.enc.env",
+			data: "DB_HOST=" + password + "\nDB_PASSWORD=" + host + "\n" + strings.Join(lines[2:], "\n"),
+			err:  "Could not decrypt value",
+		},
+	}
+
+	for _, test := range tests {
+		filename := filepath.Join(dir, test.name)
+		if err := ioutil.WriteFile(filename, []byte(test.data), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		vars, err := readVarsFile(filename, opts)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("%s: expected %q error decrypting modified file, got %+v, %v", test.name, test.err, vars, err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package kenv
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// VarSource reads Vars from a source URI, such as vault://secret/data/app
+type VarSource interface {
+	ReadVars(uri string, opts VarsOptions) (Vars, error)
+}
+
+// VarSourceFunc allows an ordinary function to be used as a VarSource
+type VarSourceFunc func(uri string, opts VarsOptions) (Vars, error)
+
+// ReadVars calls f(uri, opts)
+func (f VarSourceFunc) ReadVars(uri string, opts VarsOptions) (Vars, error) {
+	return f(uri, opts)
+}
+
+// VarFormat parses the contents of a vars file, named for errors
+type VarFormat interface {
+	ParseVars(name string, data []byte, opts VarsOptions) (Vars, error)
+}
+
+// VarFormatFunc allows an ordinary function to be used as a VarFormat
+type VarFormatFunc func(name string, data []byte, opts VarsOptions) (Vars, error)
+
+// ParseVars calls f(name, data, opts)
+func (f VarFormatFunc) ParseVars(name string, data []byte, opts VarsOptions) (Vars, error) {
+	return f(name, data, opts)
+}
+
+// defaultSourceScheme reads sources without a scheme, i.e. plain paths
+const defaultSourceScheme = "file"
+
+// defaultVarFormat parses files without a registered extension
+const defaultVarFormat = "env"
+
+// sourceSchemeRegexp matches the scheme of a source URI
+var sourceSchemeRegexp = regexp.MustCompile(`^([a-z][a-z0-9+.-]*)://`)
+
+// varSources holds the registered VarSources keyed by URI scheme
+var varSources = map[string]VarSource{}
+
+// varFormats holds the registered VarFormats keyed by name
+var varFormats = map[string]VarFormat{}
+
+// varFormatExtensions maps file extensions to VarFormat names
+var varFormatExtensions = map[string]string{}
+
+// RegisterVarSource registers a VarSource for a URI scheme, e.g. "vault"
+// for vault:// sources. A later registration for the same scheme replaces
+// the earlier one.
+func RegisterVarSource(scheme string, source VarSource) {
+	varSources[scheme] = source
+}
+
+// LookupVarSource returns the VarSource registered for a URI scheme
+func LookupVarSource(scheme string) (VarSource, bool) {
+	source, ok := varSources[scheme]
+	return source, ok
+}
+
+// RegisterVarFormat registers a VarFormat by name, and for the file
+// extensions it is chosen by, e.g. ".yaml". A later registration for the
+// same name or extension replaces the earlier one.
+func RegisterVarFormat(name string, format VarFormat, extensions ...string) {
+	varFormats[name] = format
+	for _, ext := range extensions {
+		varFormatExtensions[ext] = name
+	}
+}
+
+// LookupVarFormat returns the VarFormat registered by name
+func LookupVarFormat(name string) (VarFormat, bool) {
+	format, ok := varFormats[name]
+	return format, ok
+}
+
+// register the built-in sources and formats
+func init() {
+	RegisterVarSource(defaultSourceScheme, VarSourceFunc(readFileVars))
+	RegisterVarSource("env", VarSourceFunc(readEnvVars))
+	RegisterVarSource("vault", VarSourceFunc(readVaultVars))
+	RegisterVarSource("http", newHTTPSource())
+	RegisterVarSource("https", newHTTPSource())
+	RegisterVarSource("exec", VarSourceFunc(readExecVars))
+
+	RegisterVarFormat("env", VarFormatFunc(parseKVVars), ".env")
+	RegisterVarFormat("yaml", VarFormatFunc(parseYAMLVars), ".yml", ".yaml")
+	RegisterVarFormat("json", VarFormatFunc(parseStructuredVars), ".json")
+}
+
+// sourceScheme returns the scheme of a source URI, or file for plain paths
+func sourceScheme(uri string) string {
+	if match := sourceSchemeRegexp.FindStringSubmatch(uri); match != nil {
+		return match[1]
+	}
+	return defaultSourceScheme
+}
+
+// varFormatOf returns the VarFormat of a file name by its extension,
+// defaulting to "key=value"
+func varFormatOf(filename string) VarFormat {
+	name, ok := varFormatExtensions[path.Ext(filename)]
+	if !ok {
+		name = defaultVarFormat
+	}
+
+	format, ok := LookupVarFormat(name)
+	if !ok {
+		format = varFormats[defaultVarFormat]
+	}
+	return format
+}
+
+// readVarsFile reads the Vars of a source with the VarSource registered
+// for its scheme. Plain paths are read as files.
+func readVarsFile(uri string, opts VarsOptions) (Vars, error) {
+	Log.Debug("reading vars", "source", uri)
+
+	scheme := sourceScheme(uri)
+	source, ok := LookupVarSource(scheme)
+	if !ok {
+		return Vars{}, fmt.Errorf("%s: unsupported var source %s://", uri, scheme)
+	}
+
+	return source.ReadVars(uri, opts)
+}
+
+// trimScheme removes the scheme of a source URI
+func trimScheme(uri string) string {
+	if match := sourceSchemeRegexp.FindString(uri); match != "" {
+		return strings.TrimPrefix(uri, match)
+	}
+	return uri
+}
@@ -0,0 +1,86 @@
+package kenv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSourceScheme(t *testing.T) {
+	tests := map[string]string{
+		"fixtures/vars.env":          "file",
+		"file://fixtures/vars.env":   "file",
+		"env://APP_":                 "env",
+		"vault://secret/data/app":    "vault",
+		"https://example.com/v.json": "https",
+		"fixtures/*.env":             "file",
+	}
+
+	for uri, want := range tests {
+		if scheme := sourceScheme(uri); scheme != want {
+			t.Fatalf("%s: expected scheme %s, got %s", uri, want, scheme)
+		}
+	}
+}
+
+func TestReadVarsFileSchemes(t *testing.T) {
+	want, err := readVarsFile("fixtures/vars.yaml", VarsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars, err := readVarsFile("file://fixtures/vars.yaml", VarsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vars, want) {
+		t.Fatalf("Expected %v, got %v", want, vars)
+	}
+
+	if _, err := readVarsFile("s3://bucket/vars.env", VarsOptions{}); err == nil || !strings.Contains(err.Error(), "unsupported var source s3://") {
+		t.Fatalf("Expected unsupported source error, got %v", err)
+	}
+}
+
+func TestRegisterVarSource(t *testing.T) {
+	defer delete(varSources, "test")
+
+	RegisterVarSource("test", VarSourceFunc(func(uri string, opts VarsOptions) (Vars, error) {
+		return Vars{Var{Key: "URI", Value: uri}}, nil
+	}))
+
+	groups, err := newTargetedVarsFromFiles([]string{"test://example"}, VarsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars := joinTargetedVars(groups)
+
+	want := Vars{Var{Key: "URI", Value: "test://example"}}
+	if !reflect.DeepEqual(vars, want) {
+		t.Fatalf("Expected %v, got %v", want, vars)
+	}
+}
+
+func TestRegisterVarFormat(t *testing.T) {
+	defer delete(varFormats, "test")
+	defer delete(varFormatExtensions, ".test")
+
+	RegisterVarFormat("test", VarFormatFunc(func(name string, data []byte, opts VarsOptions) (Vars, error) {
+		return Vars{Var{Key: "NAME", Value: name}}, nil
+	}), ".test")
+
+	if _, ok := LookupVarFormat("test"); !ok {
+		t.Fatal("Expected the test format to be registered")
+	}
+
+	vars, err := varFormatOf("vars.test").ParseVars("vars.test", nil, VarsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Vars{Var{Key: "NAME", Value: "vars.test"}}
+	if !reflect.DeepEqual(vars, want) {
+		t.Fatalf("Expected %v, got %v", want, vars)
+	}
+}
@@ -1,26 +1,27 @@
-package main
+package kenv
 
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 
 	"github.com/ghodss/yaml"
 )
 
-// printJSON marshalls an interface and prints to STDOUT
-func printResource(i interface{}, yamlOutput bool) error {
+// printResource marshalls an interface and prints it to out
+func printResource(out io.Writer, i interface{}, yamlOutput bool) error {
 	if yamlOutput {
 		result, err := yaml.Marshal(&i)
 		if err != nil {
 			return err
 		}
-		fmt.Printf("---\n%s", result)
+		fmt.Fprintf(out, "---\n%s", result)
 	} else {
 		result, err := json.MarshalIndent(&i, "", "  ")
 		if err != nil {
 			return err
 		}
-		fmt.Printf("%s", result)
+		fmt.Fprintf(out, "%s", result)
 	}
 
 	return nil
@@ -1,136 +1,252 @@
-package main
+package kenv
 
 import (
+	"encoding/base64"
 	"fmt"
-	"io/ioutil"
-	"path"
-	"sort"
+	"os"
+	"regexp"
 	"strings"
+	"time"
+	"unicode/utf8"
 
-	"github.com/ghodss/yaml"
 	"k8s.io/kubernetes/pkg/api/unversioned"
 	"k8s.io/kubernetes/pkg/api/v1"
 	"k8s.io/kubernetes/pkg/util/validation"
 )
 
-// Var represents a basic key/value variable
+// Var represents a basic key/value variable. Value holds the raw bytes of
+// the variable, which are text unless marked Binary.
 type Var struct {
 	Key   string
 	Value string
+	// Binary is set when Value holds raw bytes that are not valid UTF-8
+	Binary bool `json:",omitempty"`
+	// Literal values are not interpolated
+	Literal bool `json:",omitempty"`
 }
 
 // Vars is a Var slice
 type Vars []Var
 
-// NewVarsFromFiles takes a slice of files and returns a Vars struct
-func newVarsFromFiles(files []string) (Vars, error) {
-	vars := Vars{}
+// base64KeySuffix marks var file keys whose value is base64 encoded, e.g.
+// keystore:base64=/u3+7QAAAAI=
+const base64KeySuffix = ":base64"
+
+// decodeVar decodes the value of a var whose key ends in base64KeySuffix
+// and strips the suffix. Decoded values are literal, and Binary unless they
+// are valid UTF-8.
+func decodeVar(v Var) (Var, error) {
+	n := len(v.Key) - len(base64KeySuffix)
+	if n <= 0 || !strings.EqualFold(v.Key[n:], base64KeySuffix) {
+		return v, nil
+	}
 
-	// read in vars files
-	for _, filename := range files {
-		var v Vars
-		var err error
+	// precomputed values are often wrapped at 64 or 76 columns
+	encoded := strings.Join(strings.Fields(v.Value), "")
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return v, fmt.Errorf("%s is not valid base64: %s", v.Key, err)
+	}
+
+	return Var{
+		Key:     v.Key[:n],
+		Value:   string(data),
+		Binary:  !utf8.Valid(data),
+		Literal: true,
+	}, nil
+}
+
+// VarsOptions configures how var files are read
+type VarsOptions struct {
+	// Strict turns skipped lines into errors
+	Strict bool
+	// KeySeparator joins the keys of nested YAML and JSON values
+	KeySeparator string
+	// UpperKeys upper cases the keys of YAML and JSON values
+	UpperKeys bool
+	// JSONLists keeps lists as a single JSON encoded value
+	JSONLists bool
+	// AgeKeyFile holds the age identities decrypting SOPS files
+	AgeKeyFile string
+	// Interpolate expands ${KEY} references between var values
+	Interpolate bool
+	// EnvFallback resolves ${KEY} references missing from the var files
+	// from the process environment
+	EnvFallback bool
+	// ExecTimeout bounds the commands of exec:// sources
+	ExecTimeout time.Duration
+}
 
-		if path.Ext(filename) == ".yml" || path.Ext(filename) == ".yaml" {
-			v, err = readYAMLVars(filename)
-		} else {
-			v, err = readKVVars(filename)
+// TargetedVars are Vars read from files targeted at specific containers
+type TargetedVars struct {
+	Containers ContainerSelector
+	Vars       Vars
+}
+
+// targetedSelectorRegexp matches a container selector pattern at the start
+// of a "container[,container]=file" value followed by "," or "=". Patterns
+// are names, globs or /regex/ patterns like those of ContainerSelector.
+var targetedSelectorRegexp = regexp.MustCompile(`^(/.+?/|[a-z0-9*?\[\]!^-]+)([,=])`)
+
+// newTargetedVarsFromFiles takes a slice of "file" or
+// "container[,container]=file" values and returns their Vars grouped by
+// the containers they target, in order of appearance
+func newTargetedVarsFromFiles(values []string, opts VarsOptions) ([]TargetedVars, error) {
+	groups := []TargetedVars{}
+	index := map[string]int{}
+
+	selectors := []ContainerSelector{}
+	counts := []int{}
+	vars := Vars{}
+
+	for _, value := range values {
+		containers, filename := parseTargetedFile(value)
+		if err := containers.Validate(); err != nil {
+			return groups, err
 		}
 
+		v, err := readVarsFile(filename, opts)
 		if err != nil {
-			return vars, err
+			return groups, err
 		}
 
+		selectors = append(selectors, containers)
+		counts = append(counts, len(v))
 		vars = append(vars, v...)
 	}
 
-	return vars, nil
-}
+	// references resolve across every file, not just those of a group
+	if opts.Interpolate {
+		var err error
+		if vars, err = interpolateVars(vars, opts); err != nil {
+			return groups, err
+		}
+	}
 
-// readKVVars reads files in "key=value" format and returns Vars
-func readKVVars(filename string) (Vars, error) {
-	vars := Vars{}
+	for n, containers := range selectors {
+		key := strings.Join(containers, ",")
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, TargetedVars{Containers: containers})
+		}
 
-	data, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return vars, err
+		groups[i].Vars = append(groups[i].Vars, vars[:counts[n]]...)
+		vars = vars[counts[n]:]
 	}
 
-	lines := strings.Split(string(data), "\n")
-	for _, l := range lines {
-		if l == "" {
-			continue
-		}
+	return groups, nil
+}
 
-		lSplit := strings.Split(l, "=")
+// parseTargetedFile splits a "container[,container]=file" value into its
+// ContainerSelector and filename. Existing files are never split, so a file
+// named like app=prod.env is read as is.
+func parseTargetedFile(value string) (ContainerSelector, string) {
+	if _, err := os.Stat(value); err == nil {
+		return ContainerSelector{}, value
+	}
 
-		if len(lSplit) < 2 {
-			fmt.Printf("Skipping %s; not in key=value format\n", l)
-			continue
+	containers := ContainerSelector{}
+	rest := value
+	for {
+		match := targetedSelectorRegexp.FindStringSubmatch(rest)
+		if match == nil {
+			return ContainerSelector{}, value
 		}
 
-		vars = append(vars, Var{
-			Key:   lSplit[0],
-			Value: strings.Join(lSplit[1:], "="),
-		})
+		containers = append(containers, match[1])
+		rest = rest[len(match[0]):]
+		if match[2] == "=" {
+			break
+		}
 	}
 
-	return vars, nil
+	if rest == "" {
+		return ContainerSelector{}, value
+	}
+	return containers, rest
 }
 
-// readYAMLVars reads files in "key: value" format and returns Vars
-func readYAMLVars(filename string) (Vars, error) {
-	vars := Vars{}
-
-	data, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return vars, err
+// requireUntargeted checks that groups do not target different containers.
+// envFrom references and volumes expose every key of the ConfigMap or Secret,
+// so they would leak the keys of one group into the containers of another.
+func requireUntargeted(groups []TargetedVars, flag string) error {
+	if len(groups) > 1 {
+		return fmt.Errorf("%s cannot be used with files targeted at different containers, as it exposes every key to each container", flag)
 	}
+	return nil
+}
 
-	config := make(map[string]string)
+// joinTargetedVars returns the Vars of every group
+func joinTargetedVars(groups []TargetedVars) Vars {
+	vars := Vars{}
+	for _, g := range groups {
+		vars = append(vars, g.Vars...)
+	}
+	return vars
+}
 
-	err = yaml.Unmarshal(data, &config)
-	if err != nil {
+// parseKVVars parses dotenv files in "key=value" format, decrypting them
+// when SOPS encrypted, and returns Vars
+func parseKVVars(filename string, data []byte, opts VarsOptions) (Vars, error) {
+	vars, err := parseDotenv(filename, data, opts.Strict)
+	if err != nil || !isSOPSVars(vars) {
 		return vars, err
 	}
 
-	// dirty hack for sorting
-	keys := []string{}
-	for k, _ := range config {
-		keys = append(keys, k)
+	data, err = decryptSOPS(data, "dotenv", opts)
+	if err == nil {
+		vars, err = parseSOPSDotenv(data)
 	}
-	sort.Strings(keys)
-
-	for _, k := range keys {
-		vars = append(vars, Var{
-			Key:   k,
-			Value: config[k],
-		})
+	if err != nil {
+		return vars, fmt.Errorf("%s: %s", filename, err)
 	}
 
 	return vars, nil
 }
 
-func (vars Vars) toEnvVar() []v1.EnvVar {
+// toEnvVar converts vars to plaintext EnvVars
+func (vars Vars) toEnvVar() ([]v1.EnvVar, error) {
 	envVars := []v1.EnvVar{}
 	for _, v := range vars {
+		if v.Binary {
+			return envVars, fmt.Errorf("%s is binary and cannot be injected as a plaintext variable", v.Key)
+		}
+
 		envVars = append(envVars, v1.EnvVar{
 			Name:  v.Key,
 			Value: v.Value,
 		})
 	}
 
-	return envVars
+	return envVars, nil
+}
+
+// ConfigMap is a v1.ConfigMap with the binaryData field, which the vendored
+// API types predate
+type ConfigMap struct {
+	v1.ConfigMap `json:",inline"`
+	BinaryData   map[string][]byte `json:"binaryData,omitempty"`
 }
 
-func (vars Vars) toConfigMap(name string, namespace string, convert bool) ([]v1.EnvVar, *v1.ConfigMap, error) {
+// toConfigMap converts vars to a ConfigMap resource and creates the proper
+// EnvVar ValuesFrom sources to be passed to the container. Binary vars are
+// stored as binaryData, which can only be mounted, so they get no EnvVar.
+func (vars Vars) toConfigMap(name string, namespace string, convert bool) ([]v1.EnvVar, *ConfigMap, error) {
 	envVars := []v1.EnvVar{}
 	data := make(map[string]string)
+	binaryData := make(map[string][]byte)
 
 	for _, v := range vars {
 		key, err := validateKey(v.Key, convert)
 		if err != nil {
-			return envVars, &v1.ConfigMap{}, err
+			return envVars, &ConfigMap{}, err
+		}
+
+		if v.Binary {
+			binaryData[key] = []byte(v.Value)
+			continue
 		}
 
 		data[key] = v.Value
@@ -148,16 +264,21 @@ func (vars Vars) toConfigMap(name string, namespace string, convert bool) ([]v1.
 		})
 	}
 
-	configMap := &v1.ConfigMap{
-		TypeMeta: unversioned.TypeMeta{
-			Kind:       "ConfigMap",
-			APIVersion: "v1",
-		},
-		ObjectMeta: v1.ObjectMeta{
-			Name:      name,
-			Namespace: namespace,
+	configMap := &ConfigMap{
+		ConfigMap: v1.ConfigMap{
+			TypeMeta: unversioned.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: "v1",
+			},
+			ObjectMeta: v1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Data: data,
 		},
-		Data: data,
+	}
+	if len(binaryData) > 0 {
+		configMap.BinaryData = binaryData
 	}
 
 	return envVars, configMap, nil
@@ -175,9 +296,15 @@ func (vars Vars) toSecret(name string, namespace string, convert bool) ([]v1.Env
 			return envVars, &v1.Secret{}, err
 		}
 
-		// the k8s lib will handle the base64 encoding for us
+		// the k8s lib will handle the base64 encoding for us, binary
+		// values are kept as raw bytes
 		data[key] = []byte(v.Value)
 
+		// binary values can only be mounted
+		if v.Binary {
+			continue
+		}
+
 		envVars = append(envVars, v1.EnvVar{
 			Name: v.Key,
 			ValueFrom: &v1.EnvVarSource{
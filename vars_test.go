@@ -1,7 +1,9 @@
-package main
+package kenv
 
 import (
+	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"k8s.io/kubernetes/pkg/api/unversioned"
@@ -28,19 +30,116 @@ func TestReadVarsFromFiles(t *testing.T) {
 		},
 	}
 
-	vars, err := newVarsFromFiles([]string{
+	groups, err := newTargetedVarsFromFiles([]string{
 		"fixtures/vars.env",
 		"fixtures/vars.yaml",
-	})
+	}, VarsOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	vars := joinTargetedVars(groups)
 	if !reflect.DeepEqual(want, vars) {
 		t.Fatalf("not equal, wanted: %+v, got: %+v", want, vars)
 	}
 }
 
+func TestParseTargetedFile(t *testing.T) {
+	tests := []struct {
+		value      string
+		containers ContainerSelector
+		filename   string
+	}{
+		{"fixtures/vars.env", ContainerSelector{}, "fixtures/vars.env"},
+		{"app=fixtures/vars.env", ContainerSelector{"app"}, "fixtures/vars.env"},
+		{"app,log-*=fixtures/vars.env", ContainerSelector{"app", "log-*"}, "fixtures/vars.env"},
+		{"fixtures/a=b.env", ContainerSelector{}, "fixtures/a=b.env"},
+		{"/^app$/,/log-.*/=fixtures/vars.env", ContainerSelector{"/^app$/", "/log-.*/"}, "fixtures/vars.env"},
+		{"/^(app|web){1,2}$/=fixtures/vars.env", ContainerSelector{"/^(app|web){1,2}$/"}, "fixtures/vars.env"},
+		{"app,/^log-/=vault://secret/data/app?version=2", ContainerSelector{"app", "/^log-/"}, "vault://secret/data/app?version=2"},
+		{"vault://secret/data/app?version=2", ContainerSelector{}, "vault://secret/data/app?version=2"},
+		{"/etc/kenv/a=b.env", ContainerSelector{}, "/etc/kenv/a=b.env"},
+		{"app=", ContainerSelector{}, "app="},
+		// an existing file is not split
+		{"fixtures/targeted/app=prod.env", ContainerSelector{}, "fixtures/targeted/app=prod.env"},
+	}
+
+	for _, test := range tests {
+		containers, filename := parseTargetedFile(test.value)
+		if !reflect.DeepEqual(containers, test.containers) || filename != test.filename {
+			t.Fatalf("%s: got containers %v and filename %s", test.value, containers, filename)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("fixtures/targeted"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if containers, filename := parseTargetedFile("app=prod.env"); len(containers) != 0 || filename != "app=prod.env" {
+		t.Fatalf("app=prod.env: got containers %v and filename %s", containers, filename)
+	}
+}
+
+func TestNewTargetedVarsFromFiles(t *testing.T) {
+	groups, err := newTargetedVarsFromFiles([]string{
+		"fixtures/vars.env",
+		"app=fixtures/plaintext.env",
+		"fixtures/vars.yaml",
+	}, VarsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(groups) != 2 {
+		t.Fatalf("group count is not 2: %+v", groups)
+	}
+
+	if len(groups[0].Containers) != 0 || len(groups[0].Vars) != 4 {
+		t.Fatalf("untargeted group not equal: %+v", groups[0])
+	}
+
+	if !reflect.DeepEqual(groups[1].Containers, ContainerSelector{"app"}) || len(groups[1].Vars) != 2 {
+		t.Fatalf("targeted group not equal: %+v", groups[1])
+	}
+
+	if len(joinTargetedVars(groups)) != 6 {
+		t.Fatalf("joined vars count is not 6")
+	}
+}
+
+func TestRequireUntargeted(t *testing.T) {
+	groups, err := newTargetedVarsFromFiles([]string{
+		"fixtures/vars.env",
+		"app=fixtures/plaintext.env",
+	}, VarsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := requireUntargeted(groups, "-env-from"); err == nil {
+		t.Fatal("expected error for two groups")
+	}
+
+	for _, values := range [][]string{
+		{"fixtures/vars.env", "fixtures/plaintext.env"},
+		{"app=fixtures/vars.env", "app=fixtures/plaintext.env"},
+	} {
+		groups, err := newTargetedVarsFromFiles(values, VarsOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := requireUntargeted(groups, "-env-from"); err != nil {
+			t.Fatalf("%v: %s", values, err)
+		}
+	}
+}
+
 func TestReadYAMLVars(t *testing.T) {
 	want := Vars{
 		Var{
@@ -53,7 +152,7 @@ func TestReadYAMLVars(t *testing.T) {
 		},
 	}
 
-	vars, err := readYAMLVars("fixtures/vars.yaml")
+	vars, err := readVarsFile("fixtures/vars.yaml", VarsOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -75,7 +174,7 @@ func TestReadKVVars(t *testing.T) {
 		},
 	}
 
-	vars, err := readKVVars("fixtures/vars.env")
+	vars, err := readVarsFile("fixtures/vars.env", VarsOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -108,7 +207,10 @@ func TestToEnvVar(t *testing.T) {
 		},
 	}
 
-	vars := v.toEnvVar()
+	vars, err := v.toEnvVar()
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !reflect.DeepEqual(want, vars) {
 		t.Fatalf("not equal, wanted: %+v, got: %+v", want, vars)
@@ -176,7 +278,7 @@ func TestToConfigMap(t *testing.T) {
 		t.Fatalf("EnvVars not equal, wanted: %+v, got: %+v", wantEnvVars, envVars)
 	}
 
-	if !reflect.DeepEqual(wantConfigMap, configMap) {
+	if !reflect.DeepEqual(wantConfigMap, &configMap.ConfigMap) || configMap.BinaryData != nil {
 		t.Fatalf("ConfigMap not equal, wanted: %+v, got: %+v", wantConfigMap, configMap)
 	}
 }
@@ -217,11 +319,13 @@ func TestToSecret(t *testing.T) {
 			Namespace: "bar",
 		},
 		Data: map[string][]byte{
-			"KVKey1": []byte("KVValue1"),
-			"kvkey2": []byte("kvvalue2"),
+			"KVKey1":   []byte("KVValue1"),
+			"kvkey2":   []byte("kvvalue2"),
+			"keystore": []byte("\xfe\xed\xfe\xed"),
 		},
 	}
 
+	// binary values are stored but not referenced by env vars
 	v := Vars{
 		Var{
 			Key:   "KVKey1",
@@ -231,6 +335,11 @@ func TestToSecret(t *testing.T) {
 			Key:   "kvkey2",
 			Value: "kvvalue2",
 		},
+		Var{
+			Key:    "keystore",
+			Value:  "\xfe\xed\xfe\xed",
+			Binary: true,
+		},
 	}
 
 	envVars, secret, err := v.toSecret("foo", "bar", false)
@@ -277,3 +386,48 @@ func TestValidateKey(t *testing.T) {
 		t.Fatal("expecting error")
 	}
 }
+
+func TestNewTargetedVarsFromFilesInterpolates(t *testing.T) {
+	groups, err := newTargetedVarsFromFiles([]string{
+		"web=fixtures/interpolate.env",
+		"fixtures/interpolate.yaml",
+	}, VarsOptions{Interpolate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(groups) != 2 || groups[0].Vars[0].Value != "postgres://db.example.com:5432/app" {
+		t.Fatalf("expected DB_URL resolved across files, got %+v", groups)
+	}
+}
+
+func TestReadBase64Vars(t *testing.T) {
+	want := Vars{
+		Var{Key: "keystore.jks", Value: "\xfe\xed\xfe\xed\x00\x00\x00\x02", Binary: true, Literal: true},
+		Var{Key: "password", Value: "s3cr3t", Literal: true},
+		Var{Key: "cert", Value: "1234567890", Literal: true},
+	}
+
+	vars, err := readVarsFile("fixtures/binary.env", VarsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(want, vars) {
+		t.Fatalf("not equal, wanted: %+v, got: %+v", want, vars)
+	}
+
+	vars, err = parseStructuredVars("test.json", []byte(`{"tls": {"key:base64": "/u3+7QAAAAI="}}`), VarsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(vars) != 1 || vars[0].Key != "tls_key" || !vars[0].Binary {
+		t.Fatalf("expected binary tls_key, got %+v", vars)
+	}
+
+	_, err = parseDotenv("test.env", []byte("a=1\nkey:base64=not base64!\n"), false)
+	if err == nil || !strings.HasPrefix(err.Error(), "test.env:2: key:base64 is not valid base64") {
+		t.Fatalf("expected base64 error, got %v", err)
+	}
+}
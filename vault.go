@@ -0,0 +1,159 @@
+package kenv
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// vaultTimeout bounds each request to Vault
+const vaultTimeout = 30 * time.Second
+
+// VaultSource reads the secrets of a Vault KV path, e.g.
+// vault://secret/data/app for every key of a KV v2 secret, or
+// vault://secret/data/app#password for a single key
+type VaultSource struct {
+	// Addr is the Vault server address, e.g. https://vault:8200
+	Addr string
+	// Token authenticates requests
+	Token string
+	// Namespace is the Vault Enterprise namespace, if any
+	Namespace string
+	// Client sends the requests
+	Client *http.Client
+}
+
+// readVaultVars reads a vault:// source with the Vault server and token of
+// the environment
+func readVaultVars(uri string, opts VarsOptions) (Vars, error) {
+	return newVaultSourceFromEnv().ReadVars(uri, opts)
+}
+
+// newVaultSourceFromEnv configures a VaultSource with the VAULT_ADDR,
+// VAULT_TOKEN and VAULT_NAMESPACE environment variables used by the vault
+// CLI
+func newVaultSourceFromEnv() *VaultSource {
+	return &VaultSource{
+		Addr:      os.Getenv("VAULT_ADDR"),
+		Token:     os.Getenv("VAULT_TOKEN"),
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+		Client:    &http.Client{Timeout: vaultTimeout},
+	}
+}
+
+// ReadVars reads a vault://path[?version=N][#field] source. KV v2 secrets
+// are unwrapped from their metadata, and nested values are flattened like
+// those of YAML and JSON files.
+func (s *VaultSource) ReadVars(uri string, opts VarsOptions) (Vars, error) {
+	vars := Vars{}
+
+	if s.Addr == "" {
+		return vars, fmt.Errorf("%s: VAULT_ADDR is not set", uri)
+	}
+
+	path := trimScheme(uri)
+	field := ""
+	if i := strings.Index(path, "#"); i >= 0 {
+		path, field = path[:i], path[i+1:]
+	}
+	query := ""
+	if i := strings.Index(path, "?"); i >= 0 {
+		path, query = path[:i], path[i:]
+	}
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return vars, fmt.Errorf("%s: missing secret path", uri)
+	}
+
+	data, err := s.get(path + query)
+	if err != nil {
+		return vars, fmt.Errorf("%s: %s", uri, err)
+	}
+
+	if field != "" {
+		value, ok := data[field]
+		if !ok {
+			return vars, fmt.Errorf("%s: secret has no field %s", uri, field)
+		}
+		data = map[string]interface{}{field: value}
+	}
+
+	if err := flattenVars(&vars, "", data, opts); err != nil {
+		return vars, fmt.Errorf("%s: %s", uri, err)
+	}
+
+	// secrets are used verbatim, a "$" in a password is not a reference
+	for i := range vars {
+		v, err := decodeVar(vars[i])
+		if err != nil {
+			return vars, fmt.Errorf("%s: %s", uri, err)
+		}
+		v.Literal = true
+		vars[i] = v
+	}
+
+	return vars, nil
+}
+
+// get reads the data of a secret path
+func (s *VaultSource) get(path string) (map[string]interface{}, error) {
+	req, err := http.NewRequest("GET", strings.TrimRight(s.Addr, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("X-Vault-Request", "true")
+	if s.Token != "" {
+		req.Header.Set("X-Vault-Token", s.Token)
+	}
+	if s.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.Namespace)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: vaultTimeout}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := struct {
+		Data   map[string]interface{} `json:"data"`
+		Errors []string               `json:"errors"`
+	}{}
+	decoder := json.NewDecoder(strings.NewReader(string(body)))
+	decoder.UseNumber()
+	decodeErr := decoder.Decode(&secret)
+
+	if resp.StatusCode != http.StatusOK {
+		if len(secret.Errors) > 0 {
+			return nil, fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(secret.Errors, ", "))
+		}
+		return nil, fmt.Errorf("vault returned %s", resp.Status)
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("invalid vault response: %s", decodeErr)
+	}
+
+	// KV v2 nests the secret data next to its metadata
+	nested, isData := secret.Data["data"].(map[string]interface{})
+	_, hasMetadata := secret.Data["metadata"]
+	if isData && hasMetadata && len(secret.Data) == 2 {
+		return nested, nil
+	}
+
+	return secret.Data, nil
+}
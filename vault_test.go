@@ -0,0 +1,128 @@
+package kenv
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newVaultServer stands in for a Vault server holding a KV v2 secret at
+// secret/data/app and a KV v1 secret at kv/app
+func newVaultServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errors":["permission denied"]}`)
+			return
+		}
+		if r.Header.Get("X-Vault-Namespace") != "team" {
+			t.Errorf("Expected namespace team, got %q", r.Header.Get("X-Vault-Namespace"))
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/app":
+			if r.URL.Query().Get("version") == "1" {
+				fmt.Fprint(w, `{"data":{"data":{"password":"old"},"metadata":{"version":1}}}`)
+				return
+			}
+			fmt.Fprint(w, `{"data":{"data":{"password":"pa$$word","port":5432,"tls":{"enabled":true}},"metadata":{"version":2}}}`)
+		case "/v1/kv/app":
+			fmt.Fprint(w, `{"data":{"api_key":"abc123"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors":[]}`)
+		}
+	}))
+}
+
+func TestVaultSourceReadVars(t *testing.T) {
+	server := newVaultServer(t)
+	defer server.Close()
+
+	source := &VaultSource{Addr: server.URL, Token: "s.token", Namespace: "team"}
+
+	tests := map[string]Vars{
+		"vault://secret/data/app": Vars{
+			Var{Key: "password", Value: "pa$$word", Literal: true},
+			Var{Key: "port", Value: "5432", Literal: true},
+			Var{Key: "tls_enabled", Value: "true", Literal: true},
+		},
+		"vault://secret/data/app#password": Vars{
+			Var{Key: "password", Value: "pa$$word", Literal: true},
+		},
+		"vault://secret/data/app?version=1#password": Vars{
+			Var{Key: "password", Value: "old", Literal: true},
+		},
+		"vault://kv/app": Vars{
+			Var{Key: "api_key", Value: "abc123", Literal: true},
+		},
+	}
+
+	for uri, want := range tests {
+		vars, err := source.ReadVars(uri, VarsOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(vars, want) {
+			t.Fatalf("%s: expected %v, got %v", uri, want, vars)
+		}
+	}
+}
+
+func TestVaultSourceReadVarsErrors(t *testing.T) {
+	server := newVaultServer(t)
+	defer server.Close()
+
+	tests := map[string]string{
+		"vault://secret/data/app#missing": "secret has no field missing",
+		"vault://secret/data/other":       "404 Not Found",
+		"vault://":                        "missing secret path",
+	}
+
+	source := &VaultSource{Addr: server.URL, Token: "s.token", Namespace: "team"}
+	for uri, want := range tests {
+		if _, err := source.ReadVars(uri, VarsOptions{}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected error containing %q, got %v", uri, want, err)
+		}
+	}
+
+	source.Token = "bad"
+	if _, err := source.ReadVars("vault://secret/data/app", VarsOptions{}); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("Expected permission denied error, got %v", err)
+	}
+
+	if _, err := (&VaultSource{}).ReadVars("vault://secret/data/app", VarsOptions{}); err == nil || !strings.Contains(err.Error(), "VAULT_ADDR") {
+		t.Fatalf("Expected VAULT_ADDR error, got %v", err)
+	}
+}
+
+func TestNewTargetedVarsFromFilesVault(t *testing.T) {
+	server := newVaultServer(t)
+	defer server.Close()
+
+	os.Setenv("VAULT_ADDR", server.URL)
+	defer os.Unsetenv("VAULT_ADDR")
+	os.Setenv("VAULT_TOKEN", "s.token")
+	defer os.Unsetenv("VAULT_TOKEN")
+	os.Setenv("VAULT_NAMESPACE", "team")
+	defer os.Unsetenv("VAULT_NAMESPACE")
+
+	groups, err := newTargetedVarsFromFiles([]string{"vault://kv/app", "vault://secret/data/app#password"}, VarsOptions{Interpolate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars := joinTargetedVars(groups)
+
+	want := Vars{
+		Var{Key: "api_key", Value: "abc123", Literal: true},
+		Var{Key: "password", Value: "pa$$word", Literal: true},
+	}
+	if !reflect.DeepEqual(vars, want) {
+		t.Fatalf("Expected %v, got %v", want, vars)
+	}
+}
@@ -0,0 +1,141 @@
+package kenv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/kubernetes/pkg/api/v1"
+)
+
+// configMapVolume creates a volume named "<name>-configmap" mounting the
+// ConfigMap keys as files, limited to the items whose key is in the ConfigMap
+func configMapVolume(name string, configMap *ConfigMap, items []v1.KeyToPath, mode *int32) (v1.Volume, error) {
+	keys := map[string]bool{}
+	for key := range configMap.Data {
+		keys[key] = true
+	}
+	for key := range configMap.BinaryData {
+		keys[key] = true
+	}
+
+	keyItems, err := selectMountItems(items, keys, "ConfigMap")
+	if err != nil {
+		return v1.Volume{}, err
+	}
+
+	return v1.Volume{
+		Name: name + "-configmap",
+		VolumeSource: v1.VolumeSource{
+			ConfigMap: &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: configMap.Name,
+				},
+				Items:       keyItems,
+				DefaultMode: mode,
+			},
+		},
+	}, nil
+}
+
+// secretVolume creates a volume named "<name>-secret" mounting the Secret
+// keys as files, limited to the items whose key is in the Secret
+func secretVolume(name string, secret *v1.Secret, items []v1.KeyToPath, mode *int32) (v1.Volume, error) {
+	keys := map[string]bool{}
+	for key := range secret.Data {
+		keys[key] = true
+	}
+
+	keyItems, err := selectMountItems(items, keys, "Secret")
+	if err != nil {
+		return v1.Volume{}, err
+	}
+
+	return v1.Volume{
+		Name: name + "-secret",
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{
+				SecretName:  secret.Name,
+				Items:       keyItems,
+				DefaultMode: mode,
+			},
+		},
+	}, nil
+}
+
+// selectMountItems returns the items whose key is in keys. Items without a
+// matching key would leave the volume with no items, which mounts every key,
+// so that is an error.
+func selectMountItems(items []v1.KeyToPath, keys map[string]bool, kind string) ([]v1.KeyToPath, error) {
+	keyItems := []v1.KeyToPath{}
+	for _, item := range items {
+		if keys[item.Key] {
+			keyItems = append(keyItems, item)
+		}
+	}
+
+	if len(items) > 0 && len(keyItems) == 0 {
+		return keyItems, fmt.Errorf("none of the -mount-item keys are in the %s", kind)
+	}
+	return keyItems, nil
+}
+
+// checkMountItems checks that the key of every item is mounted by one of
+// the volumes
+func checkMountItems(items []v1.KeyToPath, volumes []v1.Volume) error {
+	mounted := map[string]bool{}
+	for _, volume := range volumes {
+		volumeItems := []v1.KeyToPath{}
+		if volume.ConfigMap != nil {
+			volumeItems = volume.ConfigMap.Items
+		}
+		if volume.Secret != nil {
+			volumeItems = volume.Secret.Items
+		}
+
+		for _, item := range volumeItems {
+			mounted[item.Key] = true
+		}
+	}
+
+	for _, item := range items {
+		if !mounted[item.Key] {
+			return fmt.Errorf("-mount-item key %s is not in a mounted ConfigMap or Secret", item.Key)
+		}
+	}
+	return nil
+}
+
+// parseMountItems parses "key=path" flag values into volume items
+func parseMountItems(values []string) ([]v1.KeyToPath, error) {
+	items := []v1.KeyToPath{}
+
+	for _, value := range values {
+		split := strings.SplitN(value, "=", 2)
+		if len(split) < 2 || split[0] == "" || split[1] == "" {
+			return items, fmt.Errorf("%s is not in key=path format", value)
+		}
+
+		items = append(items, v1.KeyToPath{
+			Key:  split[0],
+			Path: split[1],
+		})
+	}
+
+	return items, nil
+}
+
+// parseFileMode parses an octal file mode such as 0440, nil when empty
+func parseFileMode(value string) (*int32, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	mode, err := strconv.ParseInt(value, 8, 32)
+	if err != nil || mode < 0 || mode > 0777 {
+		return nil, fmt.Errorf("%s is not a valid octal file mode", value)
+	}
+
+	m := int32(mode)
+	return &m, nil
+}
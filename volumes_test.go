@@ -0,0 +1,276 @@
+package kenv
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/kubernetes/pkg/api/v1"
+)
+
+func TestConfigMapVolume(t *testing.T) {
+	_, configMap, err := Vars{
+		Var{
+			Key:   "nginx.conf",
+			Value: "events {}",
+		},
+	}.toConfigMap("nginx", "default", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mode := int32(0440)
+	volume, err := configMapVolume("nginx", configMap, []v1.KeyToPath{
+		v1.KeyToPath{
+			Key:  "nginx.conf",
+			Path: "conf/nginx.conf",
+		},
+		v1.KeyToPath{
+			Key:  "tls.key",
+			Path: "tls/tls.key",
+		},
+	}, &mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := v1.Volume{
+		Name: "nginx-configmap",
+		VolumeSource: v1.VolumeSource{
+			ConfigMap: &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "nginx",
+				},
+				Items: []v1.KeyToPath{
+					v1.KeyToPath{
+						Key:  "nginx.conf",
+						Path: "conf/nginx.conf",
+					},
+				},
+				DefaultMode: &mode,
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(want, volume) {
+		t.Fatalf("volumes not equal; want: %+v, got: %+v", want, volume)
+	}
+
+	// no matching item would mount every key
+	_, err = configMapVolume("nginx", configMap, []v1.KeyToPath{
+		v1.KeyToPath{
+			Key:  "tls.key",
+			Path: "tls/tls.key",
+		},
+	}, &mode)
+	if err == nil {
+		t.Fatal("expected error for items without a matching key")
+	}
+}
+
+func TestSecretVolume(t *testing.T) {
+	_, secret, err := Vars{
+		Var{
+			Key:   "password",
+			Value: "hunter2",
+		},
+	}.toSecret("nginx", "default", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	volume, err := secretVolume("nginx", secret, []v1.KeyToPath{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if volume.Name != "nginx-secret" || volume.Secret == nil || volume.Secret.SecretName != "nginx" {
+		t.Fatalf("unexpected volume: %+v", volume)
+	}
+}
+
+func TestInjectionVolumes(t *testing.T) {
+	injection := &Injection{}
+	injection.addVolume(v1.Volume{
+		Name: "nginx-configmap",
+		VolumeSource: v1.VolumeSource{
+			ConfigMap: &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: "nginx",
+				},
+			},
+		},
+	})
+	injection.addVolumeMount(ContainerSelector{}, v1.VolumeMount{
+		Name:      "nginx-configmap",
+		MountPath: "/etc/nginx/conf.d",
+		ReadOnly:  true,
+	})
+
+	// running twice must update rather than duplicate
+	for i := 0; i < 2; i++ {
+		doc := injectFixture(t, "fixtures/deployment-volumes.yml", injection)
+
+		resource := &podTemplateResource{}
+		if err := remarshal(doc, resource); err != nil {
+			t.Fatal(err)
+		}
+
+		podSpec := resource.Spec.Template.Spec
+		if len(podSpec.Volumes) != 2 || podSpec.Volumes[1].ConfigMap == nil {
+			t.Fatalf("volumes not merged: %+v", podSpec.Volumes)
+		}
+
+		want := []v1.VolumeMount{
+			v1.VolumeMount{
+				Name:      "cache",
+				MountPath: "/var/cache/nginx",
+			},
+			v1.VolumeMount{
+				Name:      "nginx-configmap",
+				MountPath: "/etc/nginx/conf.d",
+				ReadOnly:  true,
+			},
+		}
+		if !reflect.DeepEqual(want, podSpec.Containers[0].VolumeMounts) {
+			t.Fatalf("volumeMounts not equal; want: %+v, got: %+v", want, podSpec.Containers[0].VolumeMounts)
+		}
+	}
+}
+
+func TestInjectionVolumesUnmounted(t *testing.T) {
+	injection := &Injection{}
+	injection.addVolume(v1.Volume{
+		Name: "nginx-secret",
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{
+				SecretName: "nginx",
+			},
+		},
+	})
+	injection.addVolumeMount(ContainerSelector{"sidecar"}, v1.VolumeMount{
+		Name:      "nginx-secret",
+		MountPath: "/etc/nginx/secret",
+		ReadOnly:  true,
+	})
+
+	// no container of the pod is named sidecar
+	doc := injectFixture(t, "fixtures/deployment-volumes.yml", injection)
+
+	resource := &podTemplateResource{}
+	if err := remarshal(doc, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, volume := range resource.Spec.Template.Spec.Volumes {
+		if volume.Name == "nginx-secret" {
+			t.Fatalf("unmounted volume added: %+v", volume)
+		}
+	}
+}
+
+func TestInjectionVolumeCollision(t *testing.T) {
+	injection := &Injection{}
+	injection.addVolume(v1.Volume{
+		Name: "cache",
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{
+				SecretName: "cache",
+			},
+		},
+	})
+
+	resources := parseFixture(t, "fixtures/deployment-volumes.yml")
+	injector, _ := LookupInjector(resources[0].GroupVersionKind)
+	if _, err := injector.Inject(&resources[0], injection); err == nil {
+		t.Fatalf("expected volume name collision")
+	}
+
+	injection = &Injection{}
+	injection.addVolumeMount(ContainerSelector{}, v1.VolumeMount{
+		Name:      "nginx-secret",
+		MountPath: "/var/cache/nginx",
+	})
+	if _, err := injector.Inject(&resources[0], injection); err == nil {
+		t.Fatalf("expected mountPath collision")
+	}
+}
+
+func TestCheckMountItems(t *testing.T) {
+	volumes := []v1.Volume{
+		v1.Volume{
+			Name: "nginx-secret",
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName: "nginx",
+					Items: []v1.KeyToPath{
+						v1.KeyToPath{
+							Key:  "tls.key",
+							Path: "tls/tls.key",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	items := []v1.KeyToPath{
+		v1.KeyToPath{
+			Key:  "tls.key",
+			Path: "tls/tls.key",
+		},
+	}
+	if err := checkMountItems(items, volumes); err != nil {
+		t.Fatal(err)
+	}
+
+	items = append(items, v1.KeyToPath{
+		Key:  "tls.crt",
+		Path: "tls/tls.crt",
+	})
+	if err := checkMountItems(items, volumes); err == nil {
+		t.Fatal("expected error for unknown item key")
+	}
+
+	if err := checkMountItems(items[:1], nil); err == nil {
+		t.Fatal("expected error for items without a mount")
+	}
+}
+
+func TestParseMountItems(t *testing.T) {
+	items, err := parseMountItems([]string{"tls.crt=certs/tls.crt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []v1.KeyToPath{
+		v1.KeyToPath{
+			Key:  "tls.crt",
+			Path: "certs/tls.crt",
+		},
+	}
+	if !reflect.DeepEqual(want, items) {
+		t.Fatalf("items not equal; want: %+v, got: %+v", want, items)
+	}
+
+	if _, err = parseMountItems([]string{"tls.crt"}); err == nil {
+		t.Fatalf("expected error for missing path")
+	}
+}
+
+func TestParseFileMode(t *testing.T) {
+	mode, err := parseFileMode("0440")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *mode != 0440 {
+		t.Fatalf("mode not equal: %o", *mode)
+	}
+
+	if mode, _ = parseFileMode(""); mode != nil {
+		t.Fatalf("expected nil mode")
+	}
+
+	if _, err = parseFileMode("0999"); err == nil {
+		t.Fatalf("expected error for invalid mode")
+	}
+}